@@ -1,5 +1,7 @@
 #!/usr/bin/env bash
 
 cd `dirname $0`
-CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -o ./release/micro-broker-linux
-CGO_ENABLED=0 GOOS=darwin GOARCH=amd64 go build -o ./release/micro-broker-osx
+VERSION=${VERSION:-`git describe --tags --always 2>/dev/null || echo dev`}
+LDFLAGS="-X github.com/chashu-code/micro-broker/defaults.Version=$VERSION"
+CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -ldflags "$LDFLAGS" -o ./release/micro-broker-linux
+CGO_ENABLED=0 GOOS=darwin GOARCH=amd64 go build -ldflags "$LDFLAGS" -o ./release/micro-broker-osx
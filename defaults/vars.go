@@ -20,7 +20,57 @@ const (
 	DefaultMsgQueueSize = 20
 	// DefaultMsgQueueTimeoutMSecs 默认消息队列超时时间
 	DefaultMsgQueueTimeoutMSecs = 1000
+	// DefaultSubBatchSize 订阅工作器单次最多获取消息数量
+	DefaultSubBatchSize = 10
+	// DefaultCarryBatchSize 搬运工作器单次最多处理消息数量
+	DefaultCarryBatchSize = 10
+	// DefaultCarryFlushMSecs 搬运工作器凑齐批量的最长等待毫秒数（ 0 则仅处理已在队列中的 ）
+	DefaultCarryFlushMSecs = 0
+	// DefaultMsgQueueFullPauseMSecs 消息队列已满时，订阅工作器暂停毫秒数
+	DefaultMsgQueueFullPauseMSecs = 10
 
 	// DefaultWrkPauseSecs 工作器需要间歇时，暂停秒数
 	DefaultWrkPauseSecs = 1
+
+	// DefaultDLQSize 默认死信队列最大数量
+	DefaultDLQSize = 1000
+
+	// DefaultDedupSecs 消息去重时间窗口秒数（ 0 则不去重 ）
+	DefaultDedupSecs = 0
+
+	// DefaultClaimMinSize Job 的 Data 超过该字节数则存入 redis，Job 中仅保留凭证（ 0 则不处理 ）
+	DefaultClaimMinSize = 0
+	// DefaultClaimTTLSecs 存入 redis 的 Job Data 在 Job delay + ttr 之外的保留秒数（ 覆盖 release、搁置等情形 ）
+	DefaultClaimTTLSecs = 7 * 24 * 3600
+
+	// DefaultZipMinSize 压缩协议中，超过该字节数的消息才压缩
+	DefaultZipMinSize = 1024
+
+	// DefaultDelayBatchSize 延迟请求调度工作器单次最多取出数量
+	DefaultDelayBatchSize = 100
+
+	// DefaultJobUniqueSecs Job 唯一键的保留秒数，期间相同唯一键的 Job 不再 Put
+	DefaultJobUniqueSecs = 3600
+
+	// DefaultDispatchTouchSecs 分发 Job 等待应答期间，touch 的间隔秒数（ 0 则不 touch ）
+	DefaultDispatchTouchSecs = 60
+	// DefaultDispatchTimeoutSecs 分发 Job 等待应答的最长秒数
+	DefaultDispatchTimeoutSecs = 300
+	// DefaultDispatchReleaseSecs 分发 Job 失败后，release 的延迟秒数
+	DefaultDispatchReleaseSecs = 10
+	// DefaultDispatchMaxReleases 分发 Job release 多少次后搁置（ 0 则不搁置 ）
+	DefaultDispatchMaxReleases = 0
+
+	// DefaultRegistryTTLSecs 服务注册心跳有效秒数
+	DefaultRegistryTTLSecs = 30
+	// DefaultRegistrySyncSecs 从配置 redis 同步服务注册表的间隔秒数
+	DefaultRegistrySyncSecs = 5
+
+	// DefaultBreakerFailures 连续投递失败多少次后熔断
+	DefaultBreakerFailures = 5
+	// DefaultBreakerOpenSecs 熔断持续秒数，之后进入试探
+	DefaultBreakerOpenSecs = 10
 )
+
+// Version broker 版本，构建时以 -ldflags "-X github.com/chashu-code/micro-broker/defaults.Version=x" 指定
+var Version = "dev"
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -8,6 +9,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/chashu-code/micro-broker/defaults"
 	"github.com/chashu-code/micro-broker/manage"
 	"github.com/chashu-code/micro-broker/pool"
 	"github.com/chashu-code/micro-broker/protocol"
@@ -23,6 +25,26 @@ var logPath = flag.String("log", "", "指定日志文件路径，若不指定，
 
 var pathPID = flag.String("p", "", "pid file path")
 
+var reliable = flag.Bool("reliable", false, "若指定，则以可靠模式运行（至少投递一次，需 redis >= 6.2）")
+
+var dedupSecs = flag.Int("dedup", 0, "消息去重时间窗口秒数，0 则不去重")
+var dedupShared = flag.Bool("dedup-shared", false, "若指定，则去重记录存放于配置redis，所有 broker 共享")
+
+var claimMinSize = flag.Int("claim", 0, "Job 的 Data 超过该字节数则存入本机 redis，Job 中仅保留凭证（ broker 分发时取回 ），0 则不处理")
+var claimTTLSecs = flag.Int("claim-ttl", defaults.DefaultClaimTTLSecs, "存入 redis 的 Job Data 在 Job delay + ttr 之外的保留秒数")
+
+var zipMinSize = flag.Int("zip-min", defaults.DefaultZipMinSize, "压缩协议中，超过该字节数的消息才压缩")
+
+var registryStrict = flag.Bool("registry-strict", false, "若指定，则拒绝未注册服务的请求")
+var registryRoute = flag.Bool("registry-route", false, "若指定，则未配置路由的服务投递至已注册的 broker")
+var registryList = flag.Bool("registry", false, "列出服务注册表后退出")
+
+var dispatchTubes = flag.String("dispatch", "", "由 broker 分发 Job 的管道及并发数，如 tube1:2,tube2（ 并发数默认为 1 ）")
+var dispatchTouchSecs = flag.Int("dispatch-touch", defaults.DefaultDispatchTouchSecs, "分发 Job 等待应答期间 touch 的间隔秒数，0 则不 touch")
+var dispatchTimeoutSecs = flag.Int("dispatch-timeout", defaults.DefaultDispatchTimeoutSecs, "分发 Job 等待应答的最长秒数")
+
+var dlqCmd = flag.String("dlq", "", "执行死信队列操作后退出：list（列出）、show:<id>（查看）、requeue:<id>（重新投递）")
+
 // var isMonitor = flag.Bool("monitor", false, "若指定，则以 Monitor 的方式运行")
 // var verbose = flag.Bool("verbose", false, "若指定，则以 Monitor 的方式运行")
 // var nojob = flag.Bool("nojob", false, "若指定，则不对job进行处理")
@@ -45,6 +67,24 @@ func main() {
 		conf.LogPath = *logPath
 	}
 
+	conf.Reliable = *reliable
+	conf.DedupSecs = *dedupSecs
+	conf.DedupShared = *dedupShared
+	conf.ClaimMinSize = *claimMinSize
+	conf.ClaimTTLSecs = *claimTTLSecs
+	conf.ZipMinSize = *zipMinSize
+	conf.RegistryStrict = *registryStrict
+	conf.RegistryRoute = *registryRoute
+	conf.DispatchTouchSecs = *dispatchTouchSecs
+	conf.DispatchTimeoutSecs = *dispatchTimeoutSecs
+
+	if tubeMap, err := work.ParseDispatchTubes(*dispatchTubes); err == nil {
+		conf.DispatchTubeMap = tubeMap
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	mgr := manage.NewManager(conf)
 
 	mgr.RedisPoolMap = pool.NewRedisPoolMap()
@@ -54,7 +94,22 @@ func main() {
 	mgr.ConfWrkRun = work.ConfWorkerRun
 	mgr.CrontabWrkRun = work.CrontabWorkerRun
 	mgr.ClearWrkRun = work.ClearWorkerRun
+	mgr.DelayWrkRun = work.DelayWorkerRun
+	mgr.DispatchWrkRun = work.DispatchWorkerRun
 	mgr.AddProtocolGenFn(1, protocol.NewV1Protocol)
+	mgr.AddProtocolGenFn(protocol.VersionHeaders, protocol.NewV2Protocol)
+	mgr.AddProtocolGenFn(protocol.VersionZip, protocol.ZipProtocolGen(conf.ZipMinSize))
+	mgr.AddProtocolGenFn(protocol.VersionJSON, protocol.NewJSONProtocol)
+	mgr.AddProtocolGenFn(protocol.VersionMsDeadline, protocol.NewV3Protocol)
+	mgr.AddProtocolGenFn(protocol.VersionJobOpts, protocol.NewV4Protocol)
+
+	if *dlqCmd != "" {
+		os.Exit(runDLQ(mgr, *dlqCmd))
+	}
+
+	if *registryList {
+		os.Exit(runRegistry(mgr))
+	}
 
 	// pid file
 	if *pathPID != "" {
@@ -64,3 +119,50 @@ func main() {
 
 	mgr.Start()
 }
+
+// runDLQ 执行死信队列操作，返回进程退出码
+func runDLQ(mgr *manage.Manager, cmd string) int {
+	var err error
+	var dls []*manage.DeadLetter
+
+	arr := strings.SplitN(cmd, ":", 2)
+	switch {
+	case arr[0] == "list":
+		dls, err = mgr.DLQ.List(0, -1)
+	case arr[0] == "show" && len(arr) == 2:
+		var dl *manage.DeadLetter
+		if dl, err = mgr.DLQ.Get(arr[1]); err == nil && dl == nil {
+			err = fmt.Errorf("dead letter %s not found", arr[1])
+		}
+		dls = append(dls, dl)
+	case arr[0] == "requeue" && len(arr) == 2:
+		err = mgr.DLQ.Requeue(arr[1])
+	default:
+		err = fmt.Errorf("unknown dlq command: %s", cmd)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, dl := range dls {
+		enc.Encode(dl)
+	}
+	return 0
+}
+
+// runRegistry 列出服务注册表，返回进程退出码
+func runRegistry(mgr *manage.Manager) int {
+	if _, err := mgr.Registry.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range mgr.Registry.List() {
+		enc.Encode(e)
+	}
+	return 0
+}
@@ -0,0 +1,128 @@
+package manage
+
+import "math/rand"
+
+const (
+	// BalanceRoundRobin 轮流投递（默认）
+	BalanceRoundRobin = "rr"
+	// BalanceWeight 按权重平滑轮流投递
+	BalanceWeight = "weight"
+	// BalanceRandom 随机投递
+	BalanceRandom = "random"
+	// BalanceLeast 投递至未应答请求最少的节点
+	BalanceLeast = "least"
+)
+
+// RouteNode 路由节点
+type RouteNode struct {
+	// Service 所属服务
+	Service string
+	// IP broker ip
+	IP string
+	// Weight 权重（ >= 1 ）
+	Weight int
+}
+
+// IBalancer 负载均衡策略接口，每个服务持有独立实例，调用均由 Router 加锁串行
+type IBalancer interface {
+	// Pick 从 nodes（非空）中选取目标节点
+	Pick(nodes []*RouteNode) *RouteNode
+	// Done 投递至 node 的请求已完成（收到应答或已过期）
+	Done(node *RouteNode)
+}
+
+// BalancerGenFn 负载均衡策略构造方法
+type BalancerGenFn func() IBalancer
+
+// NewRoundRobinBalancer 构造轮流投递策略
+func NewRoundRobinBalancer() IBalancer {
+	return &roundRobinBalancer{}
+}
+
+type roundRobinBalancer struct {
+	cursor int
+}
+
+func (b *roundRobinBalancer) Pick(nodes []*RouteNode) *RouteNode {
+	i := b.cursor % len(nodes)
+	b.cursor = i + 1
+	return nodes[i]
+}
+
+func (b *roundRobinBalancer) Done(node *RouteNode) {}
+
+// NewWeightBalancer 构造权重平滑轮流策略（ nginx smooth weighted round-robin ）
+func NewWeightBalancer() IBalancer {
+	return &weightBalancer{
+		currentMap: make(map[string]int),
+	}
+}
+
+type weightBalancer struct {
+	currentMap map[string]int
+}
+
+func (b *weightBalancer) Pick(nodes []*RouteNode) *RouteNode {
+	var best *RouteNode
+	total := 0
+
+	for _, node := range nodes {
+		total += node.Weight
+		b.currentMap[node.IP] += node.Weight
+		if best == nil || b.currentMap[node.IP] > b.currentMap[best.IP] {
+			best = node
+		}
+	}
+
+	b.currentMap[best.IP] -= total
+	return best
+}
+
+func (b *weightBalancer) Done(node *RouteNode) {}
+
+// NewRandomBalancer 构造随机投递策略
+func NewRandomBalancer() IBalancer {
+	return &randomBalancer{}
+}
+
+type randomBalancer struct{}
+
+func (b *randomBalancer) Pick(nodes []*RouteNode) *RouteNode {
+	return nodes[rand.Intn(len(nodes))]
+}
+
+func (b *randomBalancer) Done(node *RouteNode) {}
+
+// NewLeastBalancer 构造最少未应答请求策略
+func NewLeastBalancer() IBalancer {
+	return &leastBalancer{
+		outstandingMap: make(map[string]int),
+	}
+}
+
+type leastBalancer struct {
+	cursor         int
+	outstandingMap map[string]int
+}
+
+func (b *leastBalancer) Pick(nodes []*RouteNode) *RouteNode {
+	var best *RouteNode
+
+	// 从游标位置开始比较，未应答数相同的节点轮流投递
+	for i := range nodes {
+		node := nodes[(b.cursor+i)%len(nodes)]
+		if best == nil || b.outstandingMap[node.IP] < b.outstandingMap[best.IP] {
+			best = node
+		}
+	}
+
+	b.cursor = (b.cursor + 1) % len(nodes)
+	b.outstandingMap[best.IP]++
+	return best
+}
+
+func (b *leastBalancer) Done(node *RouteNode) {
+	if b.outstandingMap[node.IP] > 0 {
+		b.outstandingMap[node.IP]--
+	}
+}
@@ -0,0 +1,63 @@
+package manage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestNodes(weights ...int) []*RouteNode {
+	nodes := []*RouteNode{}
+	for i, w := range weights {
+		nodes = append(nodes, &RouteNode{
+			Service: "test",
+			IP:      string(rune('a' + i)),
+			Weight:  w,
+		})
+	}
+	return nodes
+}
+
+func pickIPs(b IBalancer, nodes []*RouteNode, times int) string {
+	s := ""
+	for i := 0; i < times; i++ {
+		s += b.Pick(nodes).IP
+	}
+	return s
+}
+
+func Test_Balancer_RoundRobin(t *testing.T) {
+	nodes := newTestNodes(1, 5, 1)
+	assert.Equal(t, "abcabc", pickIPs(NewRoundRobinBalancer(), nodes, 6))
+}
+
+func Test_Balancer_Weight(t *testing.T) {
+	nodes := newTestNodes(5, 1, 1)
+	assert.Equal(t, "aabacaa", pickIPs(NewWeightBalancer(), nodes, 7))
+}
+
+func Test_Balancer_Random(t *testing.T) {
+	nodes := newTestNodes(1, 1)
+	b := NewRandomBalancer()
+	for i := 0; i < 10; i++ {
+		assert.Contains(t, []string{"a", "b"}, b.Pick(nodes).IP)
+	}
+}
+
+func Test_Balancer_Least(t *testing.T) {
+	nodes := newTestNodes(1, 1, 1)
+	b := NewLeastBalancer()
+
+	// 均无未应答请求，轮流投递
+	assert.Equal(t, "abc", pickIPs(b, nodes, 3))
+
+	// a, c 完成，b 仍有未应答请求
+	b.Done(nodes[0])
+	b.Done(nodes[2])
+	b.Done(nodes[2])
+	assert.Equal(t, "ac", pickIPs(b, nodes, 2))
+
+	// b 完成
+	b.Done(nodes[1])
+	assert.Equal(t, "b", b.Pick(nodes).IP)
+}
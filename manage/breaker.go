@@ -0,0 +1,153 @@
+package manage
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// BreakerClosed 正常投递
+	BreakerClosed = "closed"
+	// BreakerOpen 熔断，直接失败
+	BreakerOpen = "open"
+	// BreakerHalfOpen 试探，仅允许一次投递
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerChangeFn 熔断器状态变化回调
+type BreakerChangeFn func(addr, from, to string)
+
+type breaker struct {
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// Breakers 以地址区分的熔断器：
+// 连续失败 Failures 次后熔断，OpenDur 后进入试探，试探成功则恢复，失败则再次熔断
+type Breakers struct {
+	Failures int
+	OpenDur  time.Duration
+	// OnChange 状态变化回调，可为 nil
+	OnChange BreakerChangeFn
+
+	lock       *sync.Mutex
+	breakerMap map[string]*breaker
+	now        func() time.Time
+}
+
+// NewBreakers 构建熔断器
+func NewBreakers(failures int, openSecs int) *Breakers {
+	return &Breakers{
+		Failures:   failures,
+		OpenDur:    time.Duration(openSecs) * time.Second,
+		lock:       new(sync.Mutex),
+		breakerMap: make(map[string]*breaker),
+		now:        time.Now,
+	}
+}
+
+func (bs *Breakers) fetch(addr string) *breaker {
+	b := bs.breakerMap[addr]
+	if b == nil {
+		b = &breaker{state: BreakerClosed}
+		bs.breakerMap[addr] = b
+	}
+	return b
+}
+
+func (bs *Breakers) change(addr string, b *breaker, to string) {
+	from := b.state
+	b.state = to
+	b.probing = false
+
+	if to == BreakerOpen {
+		b.openedAt = bs.now()
+	}
+
+	if to == BreakerClosed {
+		b.failures = 0
+	}
+
+	if bs.OnChange != nil {
+		bs.OnChange(addr, from, to)
+	}
+}
+
+// Allow 是否允许投递至 addr，熔断期满后进入试探，仅放行一次
+func (bs *Breakers) Allow(addr string) bool {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	b := bs.fetch(addr)
+
+	if b.state == BreakerOpen {
+		if bs.now().Sub(b.openedAt) < bs.OpenDur {
+			return false
+		}
+		bs.change(addr, b, BreakerHalfOpen)
+	}
+
+	if b.state == BreakerHalfOpen {
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+
+	return true
+}
+
+// IsOpen addr 是否处于熔断期（ 不改变状态 ）
+func (bs *Breakers) IsOpen(addr string) bool {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	b := bs.breakerMap[addr]
+	return b != nil && b.state == BreakerOpen && bs.now().Sub(b.openedAt) < bs.OpenDur
+}
+
+// Success 投递至 addr 成功
+func (bs *Breakers) Success(addr string) {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	b := bs.fetch(addr)
+	if b.state != BreakerClosed {
+		bs.change(addr, b, BreakerClosed)
+		return
+	}
+	b.failures = 0
+}
+
+// Failure 投递至 addr 失败
+func (bs *Breakers) Failure(addr string) {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	b := bs.fetch(addr)
+	switch b.state {
+	case BreakerHalfOpen:
+		bs.change(addr, b, BreakerOpen)
+	case BreakerClosed:
+		b.failures++
+		if b.failures >= bs.Failures {
+			bs.change(addr, b, BreakerOpen)
+		}
+	}
+}
+
+// States 返回非正常（ 熔断或试探 ）的地址及状态
+func (bs *Breakers) States() map[string]string {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	states := make(map[string]string)
+	for addr, b := range bs.breakerMap {
+		if b.state != BreakerClosed {
+			states[addr] = b.state
+		}
+	}
+	return states
+}
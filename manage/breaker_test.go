@@ -0,0 +1,58 @@
+package manage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Breakers_State(t *testing.T) {
+	bs := NewBreakers(2, 10)
+	now := time.Now()
+	bs.now = func() time.Time { return now }
+
+	changes := []string{}
+	bs.OnChange = func(addr, from, to string) {
+		changes = append(changes, addr+":"+from+">"+to)
+	}
+
+	addr := "10.0.0.1"
+	assert.True(t, bs.Allow(addr))
+
+	// 成功后重新计数
+	bs.Failure(addr)
+	bs.Success(addr)
+	bs.Failure(addr)
+	assert.True(t, bs.Allow(addr))
+	assert.Empty(t, changes)
+
+	// 连续失败，熔断
+	bs.Failure(addr)
+	assert.False(t, bs.Allow(addr))
+	assert.True(t, bs.IsOpen(addr))
+	assert.Equal(t, map[string]string{addr: BreakerOpen}, bs.States())
+
+	// 期满，仅允许一次试探，失败则再次熔断
+	now = now.Add(10 * time.Second)
+	assert.False(t, bs.IsOpen(addr))
+	assert.True(t, bs.Allow(addr))
+	assert.False(t, bs.Allow(addr))
+	bs.Failure(addr)
+	assert.True(t, bs.IsOpen(addr))
+
+	// 试探成功，恢复
+	now = now.Add(10 * time.Second)
+	assert.True(t, bs.Allow(addr))
+	bs.Success(addr)
+	assert.True(t, bs.Allow(addr))
+	assert.Empty(t, bs.States())
+
+	assert.Equal(t, []string{
+		addr + ":closed>open",
+		addr + ":open>half-open",
+		addr + ":half-open>open",
+		addr + ":open>half-open",
+		addr + ":half-open>closed",
+	}, changes)
+}
@@ -0,0 +1,137 @@
+package manage
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	rxpool "github.com/mediocregopher/radix.v2/pool"
+)
+
+// ClaimRefKey 存取凭证中的字段名，凭证格式 {"$claim": "ms:claim:<sha1>:<tid>"}
+const ClaimRefKey = "$claim"
+
+// ClaimRef 返回 data 中的存取凭证（ redis key ），非凭证返回 ""
+func ClaimRef(data interface{}) string {
+	var v interface{}
+	switch mp := data.(type) {
+	case map[string]interface{}:
+		if len(mp) != 1 {
+			return ""
+		}
+		v = mp[ClaimRefKey]
+	case map[string]string:
+		if len(mp) != 1 {
+			return ""
+		}
+		v = mp[ClaimRefKey]
+	default:
+		return ""
+	}
+
+	key, _ := v.(string)
+	return key
+}
+
+// ClaimCheck Job 大数据存取：Data 超过 Conf.ClaimMinSize 时，存入本机 redis（ 以内容 sha1 及 TID 为 key ），
+// Job 中仅保留凭证，避免超出 beanstalk job 大小限制；broker 分发、查看 Job 时以凭证取回 Data（ 以 Job 相同的协议版本打包 ）。
+// 投递至服务 inbox 的消息始终为原数据，服务无需处理凭证
+
+type ClaimCheck struct {
+	mgr *Manager
+}
+
+func (c *ClaimCheck) pool(ip string) (*rxpool.Pool, error) {
+	if c.mgr.RedisPoolMap == nil {
+		return nil, errors.New("redis pool map unset")
+	}
+
+	p, _, err := c.mgr.RedisPoolMap.FetchOrNew(ip, c.mgr.Conf.PoolSize)
+	return p, err
+}
+
+// Store Data 过大则存入 ip 对应的 redis，并替换为凭证，返回是否已替换
+// key 附带 TID（ 相同内容的 Job 互不影响 ），Job 删除时以 Drop 删除；
+// 直接从 beanstalk 删除的 Job 不会 Drop，故于 keep（ 如 Job 的 delay + ttr ）及 Conf.ClaimTTLSecs 后过期
+func (c *ClaimCheck) Store(ip string, msg *Msg, keep time.Duration) (bool, error) {
+	minSize := c.mgr.Conf.ClaimMinSize
+	if minSize <= 0 || msg.Data == nil || ClaimRef(msg.Data) != "" {
+		return false, nil
+	}
+
+	bts, err := c.mgr.Pack(&Msg{Action: msg.Action, Data: msg.Data, V: msg.V})
+	if err != nil {
+		return false, err
+	}
+
+	if len(bts) <= minSize {
+		return false, nil
+	}
+
+	p, err := c.pool(ip)
+	if err != nil {
+		return false, err
+	}
+
+	sum := sha1.Sum(bts)
+	key := c.mgr.ClaimName(hex.EncodeToString(sum[:]) + ":" + msg.TID)
+	ttlSecs := int64(keep/time.Second) + int64(c.mgr.Conf.ClaimTTLSecs)
+	if res := p.Cmd("set", key, bts, "EX", ttlSecs); res.Err != nil {
+		return false, res.Err
+	}
+
+	msg.Data = map[string]interface{}{ClaimRefKey: key}
+	return true, nil
+}
+
+// isOwn 是否为 ClaimCheck 存入的 key，避免以客户端构造的凭证读取或删除其它 key
+func (c *ClaimCheck) isOwn(key string) bool {
+	return strings.HasPrefix(key, c.mgr.ClaimName(""))
+}
+
+// Drop 删除 ip 对应 redis 中凭证 key 的数据，key 非 ClaimCheck 存入则不处理
+func (c *ClaimCheck) Drop(ip, key string) error {
+	if !c.isOwn(key) {
+		return nil
+	}
+
+	p, err := c.pool(ip)
+	if err != nil {
+		return err
+	}
+	return p.Cmd("del", key).Err
+}
+
+// Load 以凭证从 ip 对应的 redis 取回 Data，返回是否已取回；凭证 key 非 ClaimCheck 存入则视为普通 Data
+func (c *ClaimCheck) Load(ip string, msg *Msg) (bool, error) {
+	key := ClaimRef(msg.Data)
+	if !c.isOwn(key) {
+		return false, nil
+	}
+
+	p, err := c.pool(ip)
+	if err != nil {
+		return false, err
+	}
+
+	res := p.Cmd("get", key)
+	if res.Err != nil {
+		return false, res.Err
+	}
+
+	bts, err := res.Bytes()
+	if err != nil || len(bts) == 0 {
+		return false, fmt.Errorf("claim %s not found", key)
+	}
+
+	msgData, err := c.mgr.Unpack(bts)
+	if err != nil {
+		return false, err
+	}
+
+	msg.Data = msgData.Data
+	return true, nil
+}
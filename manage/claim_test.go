@@ -0,0 +1,115 @@
+package manage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chashu-code/micro-broker/defaults"
+	"github.com/chashu-code/micro-broker/pool"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDataProtocol 仅打包 string 类型的 Data
+type testDataProtocol struct {
+}
+
+func (p *testDataProtocol) BytesToMsg(bts []byte) (*Msg, error) {
+	return &Msg{Data: string(bts)}, nil
+}
+
+func (p *testDataProtocol) MsgToBytes(msg *Msg) ([]byte, error) {
+	s, ok := msg.Data.(string)
+	if !ok {
+		return nil, errors.New("data need string")
+	}
+	return []byte(s), nil
+}
+
+func genDataProtocol() IProtocol {
+	return &testDataProtocol{}
+}
+
+func Test_ClaimRef(t *testing.T) {
+	assert.Equal(t, "k", ClaimRef(map[string]interface{}{ClaimRefKey: "k"}))
+	assert.Equal(t, "k", ClaimRef(map[string]string{ClaimRefKey: "k"}))
+	assert.Equal(t, "", ClaimRef(map[string]interface{}{ClaimRefKey: "k", "x": 1}))
+	assert.Equal(t, "", ClaimRef(map[string]interface{}{ClaimRefKey: 1}))
+	assert.Equal(t, "", ClaimRef("k"))
+	assert.Equal(t, "", ClaimRef(nil))
+}
+
+func Test_ClaimCheck_StoreLoad(t *testing.T) {
+	mgr := newManager()
+	mgr.AddProtocolGenFn(1, genDataProtocol)
+	data := strings.Repeat("x", 100)
+	msg := &Msg{Action: ActReq, Data: data, V: 1}
+
+	// 未开启
+	ok, err := mgr.Claims.Store(defaults.IPLocal, msg, time.Minute)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	mgr.Conf.ClaimMinSize = 200
+	ok, _ = mgr.Claims.Store(defaults.IPLocal, msg, time.Minute)
+	assert.False(t, ok)
+
+	// 未设置 RedisPoolMap
+	mgr.Conf.ClaimMinSize = 50
+	_, err = mgr.Claims.Store(defaults.IPLocal, msg, time.Minute)
+	assert.Contains(t, err.Error(), "unset")
+	assert.Equal(t, data, msg.Data)
+
+	mgr.RedisPoolMap = pool.NewRedisPoolMap()
+	ok, err = mgr.Claims.Store(defaults.IPLocal, msg, time.Minute)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	key := ClaimRef(msg.Data)
+	assert.True(t, strings.HasPrefix(key, mgr.ClaimName("")))
+
+	// 于 keep 及 ClaimTTLSecs 后过期
+	p, _, _ := mgr.RedisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	ttl, _ := p.Cmd("ttl", key).Int()
+	assert.True(t, ttl > 60 && ttl <= 60+mgr.Conf.ClaimTTLSecs)
+
+	// 相同内容，相同 key
+	msgOther := &Msg{Action: ActReq, Data: data, V: 1}
+	mgr.Claims.Store(defaults.IPLocal, msgOther, time.Minute)
+	assert.Equal(t, key, ClaimRef(msgOther.Data))
+
+	ok, err = mgr.Claims.Load(defaults.IPLocal, msg)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, data, msg.Data)
+
+	// 非凭证
+	ok, _ = mgr.Claims.Load(defaults.IPLocal, msg)
+	assert.False(t, ok)
+
+	// 已过期
+	p.Cmd("del", key)
+	_, err = mgr.Claims.Load(defaults.IPLocal, msgOther)
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func Test_ClaimCheck_NotOwn(t *testing.T) {
+	mgr := newManager()
+	mgr.RedisPoolMap = pool.NewRedisPoolMap()
+	p, _, _ := mgr.RedisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	key := mgr.RegistryName()
+	p.Cmd("set", key, "x")
+	defer p.Cmd("del", key)
+
+	// 客户端构造的凭证，不读取也不删除其它 key
+	data := map[string]interface{}{ClaimRefKey: key}
+	msg := &Msg{Action: ActReq, Data: data, V: 1}
+	ok, err := mgr.Claims.Load(defaults.IPLocal, msg)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, data, msg.Data)
+
+	assert.Nil(t, mgr.Claims.Drop(defaults.IPLocal, key))
+	s, _ := p.Cmd("get", key).Str()
+	assert.Equal(t, "x", s)
+}
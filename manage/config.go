@@ -13,11 +13,52 @@ type Config struct {
 	SubWrkCount      int
 	CarryWorkerCount int
 
-	MsgQueueSize         int
-	MsgQueueTimeoutMSecs int
+	MsgQueueSize           int
+	MsgQueueTimeoutMSecs   int
+	MsgQueueFullPauseMSecs int
+
+	SubBatchSize    int
+	CarryBatchSize  int
+	CarryFlushMSecs int
 
 	WrkPauseSecs int
 
+	DLQSize int
+
+	BreakerFailures int
+	BreakerOpenSecs int
+
+	ClaimMinSize int
+	ClaimTTLSecs int
+
+	// ZipMinSize 压缩协议中，超过该字节数的消息才压缩
+	ZipMinSize int
+
+	DelayBatchSize int
+
+	// JobUniqueSecs Job 唯一键的保留秒数
+	JobUniqueSecs int
+
+	// DispatchTubeMap 由 broker 分发的 Job 管道及其并发数
+	DispatchTubeMap     map[string]int
+	DispatchTouchSecs   int
+	DispatchTimeoutSecs int
+	DispatchReleaseSecs int
+	DispatchMaxReleases int
+
+	RegistryTTLSecs  int
+	RegistrySyncSecs int
+	// RegistryStrict 拒绝未注册服务的请求
+	RegistryStrict bool
+	// RegistryRoute 未配置路由的服务，投递至已注册的 broker
+	RegistryRoute bool
+
+	DedupSecs int
+	// DedupShared 去重记录存放于配置 redis，所有 broker 共享
+	DedupShared bool
+	// Reliable 可靠模式：消息处理完成后才从 redis 处理中列表移除（需 redis >= 6.2）
+	Reliable bool
+
 	CrontabJobDslMap map[string]string
 	IPConf           string
 
@@ -28,16 +69,36 @@ type Config struct {
 // NewConfig 构建新的配置
 func NewConfig() *Config {
 	return &Config{
-		JobPoolSize:          defaults.DefaultJobPoolSize,
-		PoolSize:             defaults.DefaultPoolSize,
-		PopTimeoutSecs:       defaults.DefaultPopTimeoutSecs,
-		SubWrkCount:          defaults.DefaultSubWrkCount,
-		CarryWorkerCount:     defaults.DefaultCarryWorkerCount,
-		MsgQueueSize:         defaults.DefaultMsgQueueSize,
-		MsgQueueTimeoutMSecs: defaults.DefaultMsgQueueTimeoutMSecs,
-		WrkPauseSecs:         defaults.DefaultWrkPauseSecs,
-		CrontabJobDslMap:     make(map[string]string, 0),
-		IPConf:               defaults.IPLocal,
-		LogLevel:             zap.DebugLevel,
+		JobPoolSize:            defaults.DefaultJobPoolSize,
+		PoolSize:               defaults.DefaultPoolSize,
+		PopTimeoutSecs:         defaults.DefaultPopTimeoutSecs,
+		SubWrkCount:            defaults.DefaultSubWrkCount,
+		CarryWorkerCount:       defaults.DefaultCarryWorkerCount,
+		MsgQueueSize:           defaults.DefaultMsgQueueSize,
+		MsgQueueTimeoutMSecs:   defaults.DefaultMsgQueueTimeoutMSecs,
+		MsgQueueFullPauseMSecs: defaults.DefaultMsgQueueFullPauseMSecs,
+		SubBatchSize:           defaults.DefaultSubBatchSize,
+		CarryBatchSize:         defaults.DefaultCarryBatchSize,
+		CarryFlushMSecs:        defaults.DefaultCarryFlushMSecs,
+		WrkPauseSecs:           defaults.DefaultWrkPauseSecs,
+		BreakerFailures:        defaults.DefaultBreakerFailures,
+		BreakerOpenSecs:        defaults.DefaultBreakerOpenSecs,
+		DedupSecs:              defaults.DefaultDedupSecs,
+		DelayBatchSize:         defaults.DefaultDelayBatchSize,
+		JobUniqueSecs:          defaults.DefaultJobUniqueSecs,
+		DispatchTubeMap:        make(map[string]int, 0),
+		DispatchTouchSecs:      defaults.DefaultDispatchTouchSecs,
+		DispatchTimeoutSecs:    defaults.DefaultDispatchTimeoutSecs,
+		DispatchReleaseSecs:    defaults.DefaultDispatchReleaseSecs,
+		DispatchMaxReleases:    defaults.DefaultDispatchMaxReleases,
+		RegistryTTLSecs:        defaults.DefaultRegistryTTLSecs,
+		RegistrySyncSecs:       defaults.DefaultRegistrySyncSecs,
+		ClaimMinSize:           defaults.DefaultClaimMinSize,
+		ClaimTTLSecs:           defaults.DefaultClaimTTLSecs,
+		ZipMinSize:             defaults.DefaultZipMinSize,
+		DLQSize:                defaults.DefaultDLQSize,
+		CrontabJobDslMap:       make(map[string]string, 0),
+		IPConf:                 defaults.IPLocal,
+		LogLevel:               zap.DebugLevel,
 	}
 }
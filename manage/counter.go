@@ -0,0 +1,48 @@
+package manage
+
+import "sync"
+
+// Counter 计数器，按名称统计（由 ClearWorker 定期输出至日志）
+type Counter struct {
+	lock     *sync.Mutex
+	countMap map[string]int
+}
+
+// NewCounter 构建新的计数器
+func NewCounter() *Counter {
+	return &Counter{
+		lock:     new(sync.Mutex),
+		countMap: make(map[string]int),
+	}
+}
+
+// Incr 计数 +1
+func (c *Counter) Incr(name string) {
+	c.Add(name, 1)
+}
+
+// Add 计数 +n
+func (c *Counter) Add(name string, n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.countMap[name] += n
+}
+
+// Get 返回当前计数
+func (c *Counter) Get(name string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.countMap[name]
+}
+
+// Reset 返回所有计数，并清零
+func (c *Counter) Reset() map[string]int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	countMap := c.countMap
+	c.countMap = make(map[string]int)
+	return countMap
+}
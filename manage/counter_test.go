@@ -0,0 +1,23 @@
+package manage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Counter_IncrAndReset(t *testing.T) {
+	c := NewCounter()
+	assert.Equal(t, 0, c.Get("a"))
+
+	c.Incr("a")
+	c.Add("a", 2)
+	c.Incr("b")
+	assert.Equal(t, 3, c.Get("a"))
+	assert.Equal(t, 1, c.Get("b"))
+
+	countMap := c.Reset()
+	assert.Equal(t, map[string]int{"a": 3, "b": 1}, countMap)
+	assert.Equal(t, 0, c.Get("a"))
+	assert.Empty(t, c.Reset())
+}
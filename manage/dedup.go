@@ -0,0 +1,106 @@
+package manage
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mediocregopher/radix.v2/redis"
+)
+
+// DedupSharedFn 共享去重记录，返回是否已存在（ 重复 ）
+type DedupSharedFn func(key string, secs int) (bool, error)
+
+// Dedup 消息去重：记录时间窗口内出现过的 (RID, TID)，Secs 为 0 则不去重
+type Dedup struct {
+	Secs int
+	// SharedFn 跨 broker 共享去重记录，为 nil 则仅记录于本机
+	SharedFn DedupSharedFn
+
+	lock    *sync.Mutex
+	seenMap map[string]int64
+}
+
+// NewDedup 构建去重器，secs 为去重时间窗口秒数
+func NewDedup(secs int) *Dedup {
+	return &Dedup{
+		Secs:    secs,
+		lock:    new(sync.Mutex),
+		seenMap: make(map[string]int64),
+	}
+}
+
+func dedupKey(msg *Msg) string {
+	if msg.RID == "" && msg.TID == "" {
+		return ""
+	}
+	return msg.Action + ":" + msg.RID + "@" + msg.TID
+}
+
+// Seen 检测 msg 是否已在时间窗口内出现过，未出现则记录
+// 共享记录出错时视为未出现，并返回 error
+func (d *Dedup) Seen(msg *Msg) (bool, error) {
+	key := dedupKey(msg)
+	if d.Secs <= 0 || key == "" {
+		return false, nil
+	}
+
+	if d.SharedFn != nil {
+		seen, err := d.SharedFn(key, d.Secs)
+		if err != nil {
+			return false, err
+		}
+		return seen, nil
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now().Unix()
+	if expireAt, ok := d.seenMap[key]; ok && expireAt >= now {
+		return true, nil
+	}
+
+	d.seenMap[key] = now + int64(d.Secs)
+	return false, nil
+}
+
+// Expire 清理早于 now 的记录
+func (d *Dedup) Expire(now int64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for key, expireAt := range d.seenMap {
+		if expireAt < now {
+			delete(d.seenMap, key)
+		}
+	}
+}
+
+// Len 本机记录数量
+func (d *Dedup) Len() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return len(d.seenMap)
+}
+
+// dedupShared 共享去重记录，存放于配置 redis
+func (m *Manager) dedupShared(key string, secs int) (bool, error) {
+	if m.RedisPoolMap == nil {
+		return false, errors.New("redis pool map unset")
+	}
+
+	p, _, err := m.RedisPoolMap.FetchOrNew(m.Conf.IPConf, m.Conf.PoolSize)
+	if err != nil {
+		return false, err
+	}
+
+	res := p.Cmd("set", m.DedupName()+":"+key, 1, "EX", secs, "NX")
+	if res.Err != nil {
+		return false, res.Err
+	}
+
+	// 已存在则不设置，返回 nil
+	return res.IsType(redis.Nil), nil
+}
@@ -0,0 +1,70 @@
+package manage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Dedup_Seen(t *testing.T) {
+	msg := &Msg{Action: ActReq, RID: "1|a", TID: "t"}
+
+	// 未开启
+	d := NewDedup(0)
+	for i := 0; i < 2; i++ {
+		seen, _ := d.Seen(msg)
+		assert.False(t, seen)
+	}
+
+	d = NewDedup(10)
+	seen, err := d.Seen(msg)
+	assert.False(t, seen)
+	assert.Nil(t, err)
+	seen, _ = d.Seen(msg)
+	assert.True(t, seen)
+
+	// 不同 Action 或 TID，不视为重复；无 RID、TID 不去重
+	for _, m := range []*Msg{
+		{Action: ActJob, RID: "1|a", TID: "t"},
+		{Action: ActReq, RID: "1|a", TID: "x"},
+		{Action: ActReq},
+		{Action: ActReq},
+	} {
+		seen, _ = d.Seen(m)
+		assert.False(t, seen)
+	}
+
+	// 过期清理
+	assert.Equal(t, 3, d.Len())
+	d.Expire(time.Now().Unix() + 11)
+	assert.Equal(t, 0, d.Len())
+	seen, _ = d.Seen(msg)
+	assert.False(t, seen)
+}
+
+func Test_Dedup_SeenShared(t *testing.T) {
+	d := NewDedup(10)
+	keys := map[string]bool{}
+	d.SharedFn = func(key string, secs int) (bool, error) {
+		seen := keys[key]
+		keys[key] = true
+		return seen, nil
+	}
+
+	msg := &Msg{Action: ActReq, RID: "1|a", TID: "t"}
+	seen, _ := d.Seen(msg)
+	assert.False(t, seen)
+	seen, _ = d.Seen(msg)
+	assert.True(t, seen)
+	assert.Equal(t, 0, d.Len())
+
+	// 共享记录出错，放行
+	d.SharedFn = func(key string, secs int) (bool, error) {
+		return true, errors.New("redis down")
+	}
+	seen, err := d.Seen(msg)
+	assert.False(t, seen)
+	assert.Equal(t, "redis down", err.Error())
+}
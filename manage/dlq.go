@@ -0,0 +1,172 @@
+package manage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chashu-code/micro-broker/defaults"
+	"github.com/mediocregopher/radix.v2/redis"
+)
+
+const (
+	// DLStageUnpack bytes => msg 失败
+	DLStageUnpack = "unpack"
+	// DLStagePid 应答无法解析 pid
+	DLStagePid = "pid"
+	// DLStagePack msg => bytes 失败
+	DLStagePack = "pack"
+	// DLStagePush 推送至目标 redis 失败
+	DLStagePush = "push"
+	// DLStageBreaker 目标熔断中，未推送
+	DLStageBreaker = "breaker"
+)
+
+// DeadLetter 死信：无法解析或投递失败的消息
+type DeadLetter struct {
+	ID     string `json:"id"`
+	Stage  string `json:"stage"`
+	Reason string `json:"reason"`
+	Time   int64  `json:"time"`
+	TID    string `json:"tid,omitempty"`
+	RID    string `json:"rid,omitempty"`
+	// DestIP, Key 重新投递的目标 redis 及 list，为空则无法重新投递
+	DestIP string `json:"dest_ip,omitempty"`
+	Key    string `json:"key,omitempty"`
+	// Raw 原始消息 bytes（含版本号）
+	Raw []byte `json:"raw,omitempty"`
+}
+
+// NewDeadLetter 构造死信，msg 可为 nil
+func NewDeadLetter(stage string, err error, msg *Msg, raw []byte) *DeadLetter {
+	dl := &DeadLetter{
+		Stage: stage,
+		Time:  time.Now().Unix(),
+		Raw:   raw,
+	}
+
+	if err != nil {
+		dl.Reason = err.Error()
+	}
+
+	if msg != nil {
+		dl.TID = msg.TID
+		dl.RID = msg.RID
+	}
+
+	return dl
+}
+
+// DeadLetterQueue 死信队列，存放于本机 redis list（新的在前，超出 Conf.DLQSize 则丢弃最旧的）
+type DeadLetterQueue struct {
+	mgr *Manager
+}
+
+func (q *DeadLetterQueue) cmd(cmd string, args ...interface{}) *redis.Resp {
+	if q.mgr.RedisPoolMap == nil {
+		return redis.NewResp(errors.New("redis pool map unset"))
+	}
+
+	p, _, err := q.mgr.RedisPoolMap.FetchOrNew(defaults.IPLocal, q.mgr.Conf.PoolSize)
+	if err != nil {
+		return redis.NewResp(err)
+	}
+
+	return p.Cmd(cmd, args...)
+}
+
+// Push 存入死信
+func (q *DeadLetterQueue) Push(dl *DeadLetter) error {
+	if dl.ID == "" {
+		dl.ID = q.mgr.NextTID()
+	}
+
+	bts, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+
+	name := q.mgr.DLQName()
+	if res := q.cmd("lpush", name, bts); res.Err != nil {
+		return res.Err
+	}
+	return q.cmd("ltrim", name, 0, q.mgr.Conf.DLQSize-1).Err
+}
+
+// Len 死信数量
+func (q *DeadLetterQueue) Len() (int, error) {
+	return q.cmd("llen", q.mgr.DLQName()).Int()
+}
+
+// List 返回 [start, stop] 范围内的死信（同 lrange）
+func (q *DeadLetterQueue) List(start, stop int) ([]*DeadLetter, error) {
+	dls, _, err := q.list(start, stop)
+	return dls, err
+}
+
+func (q *DeadLetterQueue) list(start, stop int) ([]*DeadLetter, [][]byte, error) {
+	lstBytes, err := q.cmd("lrange", q.mgr.DLQName(), start, stop).ListBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dls := make([]*DeadLetter, 0, len(lstBytes))
+	for _, bts := range lstBytes {
+		dl := &DeadLetter{}
+		if err = json.Unmarshal(bts, dl); err != nil {
+			return nil, nil, err
+		}
+		dls = append(dls, dl)
+	}
+
+	return dls, lstBytes, nil
+}
+
+// Get 返回指定 id 的死信，未找到返回 nil
+func (q *DeadLetterQueue) Get(id string) (*DeadLetter, error) {
+	dl, _, err := q.find(id)
+	return dl, err
+}
+
+func (q *DeadLetterQueue) find(id string) (*DeadLetter, []byte, error) {
+	dls, lstBytes, err := q.list(0, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, dl := range dls {
+		if dl.ID == id {
+			return dl, lstBytes[i], nil
+		}
+	}
+
+	return nil, nil, nil
+}
+
+// Requeue 将指定 id 的死信重新推送至原目标 list，成功后移出死信队列
+func (q *DeadLetterQueue) Requeue(id string) error {
+	dl, bts, err := q.find(id)
+	if err != nil {
+		return err
+	}
+
+	if dl == nil {
+		return fmt.Errorf("dead letter %s not found", id)
+	}
+
+	if dl.Key == "" || len(dl.Raw) == 0 {
+		return fmt.Errorf("dead letter %s can't requeue", id)
+	}
+
+	p, _, err := q.mgr.RedisPoolMap.FetchOrNew(dl.DestIP, q.mgr.Conf.PoolSize)
+	if err != nil {
+		return err
+	}
+
+	if res := p.Cmd("rpush", dl.Key, dl.Raw); res.Err != nil {
+		return res.Err
+	}
+
+	return q.cmd("lrem", q.mgr.DLQName(), 1, bts).Err
+}
@@ -0,0 +1,76 @@
+package manage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/chashu-code/micro-broker/defaults"
+	"github.com/chashu-code/micro-broker/pool"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DeadLetter_New(t *testing.T) {
+	msg := &Msg{RID: "1|a", TID: "t"}
+	dl := NewDeadLetter(DLStagePush, errors.New("test error"), msg, []byte{1})
+	assert.Equal(t, DLStagePush, dl.Stage)
+	assert.Equal(t, "test error", dl.Reason)
+	assert.Equal(t, "1|a", dl.RID)
+	assert.Equal(t, "t", dl.TID)
+	assert.NotZero(t, dl.Time)
+
+	dl = NewDeadLetter(DLStageUnpack, nil, nil, nil)
+	assert.Empty(t, dl.Reason)
+	assert.Empty(t, dl.TID)
+}
+
+func Test_DeadLetterQueue_Unset(t *testing.T) {
+	mgr := newManager()
+	err := mgr.DLQ.Push(NewDeadLetter(DLStagePack, nil, nil, nil))
+	assert.Contains(t, err.Error(), "unset")
+}
+
+func Test_DeadLetterQueue_PushListRequeue(t *testing.T) {
+	mgr := newManager()
+	mgr.Conf.DLQSize = 2
+	mgr.RedisPoolMap = pool.NewRedisPoolMap()
+	p, _, _ := mgr.RedisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	p.Cmd("del", mgr.DLQName())
+
+	// 超出大小，丢弃最旧的
+	for _, stage := range []string{DLStageUnpack, DLStagePack, DLStagePush} {
+		assert.Nil(t, mgr.DLQ.Push(NewDeadLetter(stage, nil, nil, []byte{1, 'a'})))
+	}
+	n, _ := mgr.DLQ.Len()
+	assert.Equal(t, 2, n)
+
+	dls, err := mgr.DLQ.List(0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, DLStagePush, dls[0].Stage)
+	assert.Equal(t, DLStagePack, dls[1].Stage)
+
+	dl, err := mgr.DLQ.Get(dls[1].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 'a'}, dl.Raw)
+
+	dl, err = mgr.DLQ.Get("unfound")
+	assert.Nil(t, err)
+	assert.Nil(t, dl)
+
+	// 无目标，无法重新投递
+	assert.Contains(t, mgr.DLQ.Requeue(dls[0].ID).Error(), "can't requeue")
+	assert.Contains(t, mgr.DLQ.Requeue("unfound").Error(), "not found")
+
+	key := mgr.Inbox("test-dlq")
+	p.Cmd("del", key)
+	dl = NewDeadLetter(DLStagePush, nil, nil, []byte{1, 'b'})
+	dl.DestIP, dl.Key = defaults.IPLocal, key
+	mgr.DLQ.Push(dl)
+
+	assert.Nil(t, mgr.DLQ.Requeue(dl.ID))
+	bts, _ := p.Cmd("lpop", key).Bytes()
+	assert.Equal(t, []byte{1, 'b'}, bts)
+	dl, _ = mgr.DLQ.Get(dl.ID)
+	assert.Nil(t, dl)
+
+	p.Cmd("del", mgr.DLQName())
+}
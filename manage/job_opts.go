@@ -0,0 +1,83 @@
+package manage
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/uber-go/zap"
+)
+
+// jobTubeMaxLen beanstalk tube 名称的最大长度
+const jobTubeMaxLen = 200
+
+// JobOpts Job 选项，Pri、Delay、TTR 为 nil 则未设置
+type JobOpts struct {
+	// Pri 优先级（ 越小越优先 ）
+	Pri *int64
+	// Delay 延迟秒数
+	Delay *int64
+	// TTR 执行超时秒数，至少为 1
+	TTR *int64
+	// Tube 指定 tube，为空则使用 Topic-Channel
+	Tube string
+	// Unique 唯一键，同一 tube 内 JobUniqueSecs 秒内仅 Put 一次
+	Unique string
+}
+
+// Validate 检查各选项的取值范围
+func (o *JobOpts) Validate() error {
+	if o.Pri != nil && (*o.Pri < 0 || *o.Pri > math.MaxUint32) {
+		return fmt.Errorf("Error job pri: %d", *o.Pri)
+	}
+
+	if o.Delay != nil && (*o.Delay < 0 || *o.Delay > math.MaxUint32) {
+		return fmt.Errorf("Error job delay: %d", *o.Delay)
+	}
+
+	if o.TTR != nil && (*o.TTR < 1 || *o.TTR > math.MaxUint32) {
+		return fmt.Errorf("Error job ttr: %d", *o.TTR)
+	}
+
+	if o.Tube != "" && !isTubeName(o.Tube) {
+		return fmt.Errorf("Error job tube: %s", o.Tube)
+	}
+
+	return nil
+}
+
+// MarshalLog zap log 序列化接口方法
+func (o *JobOpts) MarshalLog(kv zap.KeyValue) error {
+	if o.Pri != nil {
+		kv.AddInt64("pri", *o.Pri)
+	}
+	if o.Delay != nil {
+		kv.AddInt64("delay", *o.Delay)
+	}
+	if o.TTR != nil {
+		kv.AddInt64("ttr", *o.TTR)
+	}
+	if o.Tube != "" {
+		kv.AddString("tube", o.Tube)
+	}
+	if o.Unique != "" {
+		kv.AddString("uniq", o.Unique)
+	}
+	return nil
+}
+
+// isTubeName 是否为合法的 beanstalk tube 名称（ 字母、数字及 -+/;.$_()，不以 - 开头 ）
+func isTubeName(name string) bool {
+	if len(name) > jobTubeMaxLen || name[0] == '-' {
+		return false
+	}
+
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '+', c == '/', c == ';', c == '.', c == '$', c == '_', c == '(', c == ')':
+		default:
+			return false
+		}
+	}
+	return true
+}
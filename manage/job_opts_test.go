@@ -0,0 +1,30 @@
+package manage
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_JobOpts_Validate(t *testing.T) {
+	i64 := func(v int64) *int64 { return &v }
+
+	assert.Nil(t, (&JobOpts{}).Validate())
+	assert.Nil(t, (&JobOpts{Pri: i64(0), Delay: i64(0), TTR: i64(1), Tube: "a-b_c.d(1)", Unique: "u"}).Validate())
+	assert.Nil(t, (&JobOpts{Pri: i64(math.MaxUint32)}).Validate())
+
+	wrongs := []*JobOpts{
+		{Pri: i64(-1)},
+		{Pri: i64(math.MaxUint32 + 1)},
+		{Delay: i64(-1)},
+		{TTR: i64(0)},
+		{Tube: "-a"},
+		{Tube: "a b"},
+		{Tube: strings.Repeat("a", jobTubeMaxLen+1)},
+	}
+	for _, opts := range wrongs {
+		assert.Error(t, opts.Validate())
+	}
+}
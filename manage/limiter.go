@@ -0,0 +1,238 @@
+package manage
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mediocregopher/radix.v2/util"
+)
+
+const (
+	// LimitPrefixBID 按调用方 broker 限流的配置名前缀
+	LimitPrefixBID = "bid:"
+	// LimitPrefixPid 按调用方进程限流的配置名前缀，其后为 "<bid>/<pid>"（ 不同主机的 pid 可能相同 ）
+	LimitPrefixPid = "pid:"
+	// LimitShared 跨 broker 共享令牌桶的 dsl 后缀
+	LimitShared = "shared"
+)
+
+// luaTokenBucket 共享令牌桶，ARGV: rate, burst, now(ms), n（ 取出令牌数，-1 为归还 ），返回 1 允许，0 超限
+const luaTokenBucket = `
+local rate, burst, now, n = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local b = redis.call('hmget', KEYS[1], 't', 'ts')
+local tokens = tonumber(b[1]) or burst
+local ts = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local ok = 0
+if tokens >= n then
+	tokens = math.min(burst, tokens - n)
+	ok = 1
+end
+redis.call('hmset', KEYS[1], 't', tokens, 'ts', now)
+redis.call('pexpire', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return ok
+`
+
+// LimitSharedFn 共享令牌桶取出 n 个令牌（ n 为 -1 则归还 1 个 ）
+type LimitSharedFn func(key string, rate, burst, n int) (bool, error)
+
+type limitRule struct {
+	rate   int
+	burst  int
+	shared bool
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter 令牌桶限流，按 ServiceName（ 或 Topic ）及调用方（ bid:ip, pid:ip/pid ）配置
+type Limiter struct {
+	// V 当前限流配置版本
+	V string
+	// SharedFn 共享令牌桶实现，为 nil 则共享配置按本机令牌桶处理
+	SharedFn LimitSharedFn
+
+	lock      *sync.Mutex
+	ruleMap   map[string]*limitRule
+	bucketMap map[string]*tokenBucket
+	now       func() time.Time
+}
+
+// NewLimiter 构建空的限流器（ 不限流 ）
+func NewLimiter() *Limiter {
+	return &Limiter{
+		lock:      new(sync.Mutex),
+		ruleMap:   make(map[string]*limitRule),
+		bucketMap: make(map[string]*tokenBucket),
+		now:       time.Now,
+	}
+}
+
+// Update 使用配置更新限流规则，"v" 为版本字段，返回配置有误（被忽略）的名称
+func (l *Limiter) Update(v string, confMap map[string]string) []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	ruleMap := make(map[string]*limitRule)
+	wrongs := []string{}
+
+	for name, dsl := range confMap {
+		if name == "v" {
+			continue
+		}
+
+		if rule := dslToLimitRule(dsl); rule != nil {
+			ruleMap[name] = rule
+		} else {
+			wrongs = append(wrongs, name)
+		}
+	}
+
+	// 保留规则未变的令牌桶，避免每次重载配置都补满令牌
+	bucketMap := make(map[string]*tokenBucket)
+	for name, rule := range ruleMap {
+		if old := l.ruleMap[name]; old != nil && *old == *rule && l.bucketMap[name] != nil {
+			bucketMap[name] = l.bucketMap[name]
+		}
+	}
+
+	l.V = v
+	l.ruleMap = ruleMap
+	l.bucketMap = bucketMap
+	return wrongs
+}
+
+// Allow 检测请求是否超出限流，返回超限的配置名，未超限返回 ""
+// 超限时归还已从其它令牌桶取出的令牌；共享令牌桶出错时放行，并返回 error
+func (l *Limiter) Allow(msg *Msg) (string, error) {
+	names := []string{}
+
+	for _, name := range []string{msg.ServiceName(), msg.Topic} {
+		if l.rule(name) != nil {
+			names = append(names, name)
+			break
+		}
+	}
+
+	names = append(names, LimitPrefixBID+msg.BID)
+	if pid, err := msg.PidOfRID(); err == nil {
+		names = append(names, LimitPrefixPid+msg.BID+"/"+pid)
+	}
+
+	var errShared error
+	for i, name := range names {
+		ok, err := l.take(name, 1)
+		if err != nil {
+			errShared = err
+		}
+		if !ok {
+			for _, taken := range names[:i] {
+				l.take(taken, -1)
+			}
+			return name, nil
+		}
+	}
+
+	return "", errShared
+}
+
+func (l *Limiter) rule(name string) *limitRule {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.ruleMap[name]
+}
+
+// take 取出 n 个令牌（ n 为 -1 则归还 1 个 ）
+func (l *Limiter) take(name string, n int) (bool, error) {
+	l.lock.Lock()
+	rule := l.ruleMap[name]
+	if rule == nil {
+		l.lock.Unlock()
+		return true, nil
+	}
+
+	if rule.shared && l.SharedFn != nil {
+		l.lock.Unlock()
+		ok, err := l.SharedFn(name, rule.rate, rule.burst, n)
+		if err != nil {
+			return true, err
+		}
+		return ok, nil
+	}
+	defer l.lock.Unlock()
+
+	now := l.now()
+	b := l.bucketMap[name]
+	if b == nil {
+		b = &tokenBucket{tokens: float64(rule.burst), last: now}
+		l.bucketMap[name] = b
+	}
+
+	b.tokens = math.Min(float64(rule.burst), b.tokens+now.Sub(b.last).Seconds()*float64(rule.rate))
+	b.last = now
+
+	if b.tokens < float64(n) {
+		return false, nil
+	}
+	b.tokens = math.Min(float64(rule.burst), b.tokens-float64(n))
+	return true, nil
+}
+
+// dslToLimitRule dsl 规则
+// 100 => 每秒 100 个，可突发 100 个
+// 100/300 => 每秒 100 个，可突发 300 个
+// 100/300|shared => 同上，所有 broker 共享令牌桶
+func dslToLimitRule(dsl string) *limitRule {
+	ss := strings.SplitN(dsl, "|", 2)
+
+	rule := &limitRule{}
+	if len(ss) == 2 {
+		if strings.TrimSpace(ss[1]) != LimitShared {
+			return nil
+		}
+		rule.shared = true
+	}
+
+	arr := strings.SplitN(strings.TrimSpace(ss[0]), "/", 2)
+
+	var err error
+	if rule.rate, err = strconv.Atoi(arr[0]); err != nil || rule.rate < 1 {
+		return nil
+	}
+
+	rule.burst = rule.rate
+	if len(arr) == 2 {
+		if rule.burst, err = strconv.Atoi(arr[1]); err != nil || rule.burst < 1 {
+			return nil
+		}
+	}
+
+	return rule
+}
+
+// limitShared 共享令牌桶，存放于配置 redis
+func (m *Manager) limitShared(name string, rate, burst, n int) (bool, error) {
+	if m.RedisPoolMap == nil {
+		return false, errors.New("redis pool map unset")
+	}
+
+	p, _, err := m.RedisPoolMap.FetchOrNew(m.Conf.IPConf, m.Conf.PoolSize)
+	if err != nil {
+		return false, err
+	}
+
+	now := NowMs()
+	res := util.LuaEval(p, luaTokenBucket, 1, m.LimitName()+":"+name, rate, burst, now, n)
+	ok, err := res.Int()
+	if err != nil {
+		return false, err
+	}
+	return ok == 1, nil
+}
@@ -0,0 +1,148 @@
+package manage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Limiter_dslToLimitRule(t *testing.T) {
+	for _, dsl := range []string{"", "x", "0", "10/0", "10/x", "10|unknown"} {
+		assert.Nil(t, dslToLimitRule(dsl), dsl)
+	}
+
+	assert.Equal(t, &limitRule{rate: 10, burst: 10}, dslToLimitRule("10"))
+	assert.Equal(t, &limitRule{rate: 10, burst: 30, shared: true}, dslToLimitRule(" 10/30 | shared"))
+}
+
+func Test_Limiter_Allow(t *testing.T) {
+	l := NewLimiter()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	msg := &Msg{Topic: "user", Channel: "login", BID: "10.0.0.1", RID: "100|a"}
+	name, err := l.Allow(msg)
+	assert.Equal(t, "", name)
+	assert.Nil(t, err)
+
+	wrongs := l.Update("1", map[string]string{
+		"v":                "1",
+		"user/login":       "1/2",
+		"user":             "100",
+		"bid:10.0.0.2":     "1",
+		"pid:10.0.0.1/200": "1",
+		"wrong":            "x",
+	})
+	assert.Equal(t, "1", l.V)
+	assert.Equal(t, []string{"wrong"}, wrongs)
+
+	// 优先匹配 ServiceName，可突发 2 个
+	for i := 0; i < 2; i++ {
+		name, _ = l.Allow(msg)
+		assert.Equal(t, "", name)
+	}
+	name, _ = l.Allow(msg)
+	assert.Equal(t, "user/login", name)
+
+	// 按时间补充令牌
+	now = now.Add(time.Second)
+	name, _ = l.Allow(msg)
+	assert.Equal(t, "", name)
+
+	// 调用方
+	msg.BID = "10.0.0.2"
+	msg.Channel = "logout"
+	l.Allow(msg)
+	name, _ = l.Allow(msg)
+	assert.Equal(t, "bid:10.0.0.2", name)
+
+	msg.BID = "10.0.0.1"
+	msg.RID = "200|a"
+	l.Allow(msg)
+	name, _ = l.Allow(msg)
+	assert.Equal(t, "pid:10.0.0.1/200", name)
+
+	// 不同主机的相同 pid，互不影响
+	msg.BID = "10.0.0.3"
+	name, _ = l.Allow(msg)
+	assert.Equal(t, "", name)
+}
+
+func Test_Limiter_AllowShared(t *testing.T) {
+	l := NewLimiter()
+	l.Update("1", map[string]string{"user": "1/5|shared"})
+
+	taken := []string{}
+	l.SharedFn = func(name string, rate, burst, n int) (bool, error) {
+		taken = append(taken, name)
+		return false, nil
+	}
+
+	msg := &Msg{Topic: "user"}
+	name, err := l.Allow(msg)
+	assert.Equal(t, "user", name)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"user"}, taken)
+
+	// 共享令牌桶出错，放行
+	l.SharedFn = func(name string, rate, burst, n int) (bool, error) {
+		return false, errors.New("redis down")
+	}
+	name, err = l.Allow(msg)
+	assert.Equal(t, "", name)
+	assert.Equal(t, "redis down", err.Error())
+}
+
+func Test_Limiter_AllowRefund(t *testing.T) {
+	l := NewLimiter()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	l.Update("1", map[string]string{"user": "1/2", "bid:10.0.0.1": "1/2", "pid:10.0.0.1/100": "1/1"})
+
+	msg := &Msg{Topic: "user", BID: "10.0.0.1", RID: "100|a"}
+	name, _ := l.Allow(msg)
+	assert.Equal(t, "", name)
+
+	// pid 超限，归还 user 及 bid 的令牌
+	for i := 0; i < 3; i++ {
+		name, _ = l.Allow(msg)
+		assert.Equal(t, "pid:10.0.0.1/100", name)
+	}
+	msg.RID = "200|a"
+	name, _ = l.Allow(msg)
+	assert.Equal(t, "", name)
+	name, _ = l.Allow(msg)
+	assert.Equal(t, "user", name)
+
+	// 共享令牌桶同样归还
+	l.Update("2", map[string]string{"user": "1/5|shared", "pid:10.0.0.1/100": "2/1"})
+	taken := map[string]int{}
+	l.SharedFn = func(name string, rate, burst, n int) (bool, error) {
+		taken[name] += n
+		return true, nil
+	}
+	msg.RID = "100|a"
+	l.Allow(msg)
+	name, _ = l.Allow(msg)
+	assert.Equal(t, "pid:10.0.0.1/100", name)
+	assert.Equal(t, map[string]int{"user": 1}, taken)
+}
+
+func Test_Limiter_UpdateKeepBucket(t *testing.T) {
+	l := NewLimiter()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	l.Update("1", map[string]string{"user": "1/1", "order": "1/1"})
+
+	l.Allow(&Msg{Topic: "user"})
+	l.Allow(&Msg{Topic: "order"})
+
+	// 规则未变的令牌桶保留，不因重载而补满
+	l.Update("2", map[string]string{"user": "1/1", "order": "2/2"})
+	name, _ := l.Allow(&Msg{Topic: "user"})
+	assert.Equal(t, "user", name)
+	name, _ = l.Allow(&Msg{Topic: "order"})
+	assert.Equal(t, "", name)
+}
@@ -52,14 +52,27 @@ type Manager struct {
 	Log          zap.Logger
 	logWriter    *os.File
 	MsgQ         *MsgQueue
-
-	ip string
+	Router       *Router
+	Counter      *Counter
+	Pending      *Pending
+	DLQ          *DeadLetterQueue
+	Breakers     *Breakers
+	Limiter      *Limiter
+	Dedup        *Dedup
+	Claims       *ClaimCheck
+	Registry     *Registry
+	Versions     *VersionTable
+
+	ip        string
+	startTime time.Time
 
 	SubWrkRun      WrkRunFn
 	CarryWrkRun    WrkRunFn
 	ConfWrkRun     WrkRunFn
 	CrontabWrkRun  WrkRunFn
 	ClearWrkRun    WrkRunFn
+	DelayWrkRun    WrkRunFn
+	DispatchWrkRun WrkRunFn
 	protocolGenMap map[uint]ProtocolGenFn
 
 	chanStop      chan struct{}
@@ -77,15 +90,46 @@ func NewManager(conf *Config) *Manager {
 		Conf:           conf,
 		protocolGenMap: make(map[uint]ProtocolGenFn),
 		tidLock:        new(sync.RWMutex),
+		Router:         NewRouter(),
+		Counter:        NewCounter(),
+		Pending:        NewPending(),
+		Limiter:        NewLimiter(),
+		Versions:       NewVersionTable(),
+		startTime:      time.Now(),
 	}
 	m.Log = m.genLog(conf.LogPath)
 	m.chanStop = make(chan struct{}, 0)
 	m.waitGroupStop = &sync.WaitGroup{}
 	m.MsgQ = NewMsgQueueWithSize(conf.MsgQueueTimeoutMSecs, conf.MsgQueueSize)
+	m.DLQ = &DeadLetterQueue{mgr: m}
+	m.Claims = &ClaimCheck{mgr: m}
+	m.Registry = NewRegistry(m)
+	m.Breakers = NewBreakers(conf.BreakerFailures, conf.BreakerOpenSecs)
+	m.Breakers.OnChange = m.breakerChange
+	m.Limiter.SharedFn = m.limitShared
+	m.Dedup = NewDedup(conf.DedupSecs)
+	if conf.DedupShared {
+		m.Dedup.SharedFn = m.dedupShared
+	}
 
 	return m
 }
 
+// breakerChange 熔断器状态变化，记录日志及计数
+func (m *Manager) breakerChange(addr, from, to string) {
+	m.Counter.Incr("breaker." + to)
+	m.Log.Warn("breaker state change",
+		zap.String("addr", addr),
+		zap.String("from", from),
+		zap.String("to", to),
+	)
+}
+
+// Uptime 运行时长
+func (m *Manager) Uptime() time.Duration {
+	return time.Since(m.startTime)
+}
+
 // LogSync 日志同步
 func (m *Manager) LogSync() error {
 	if m.logWriter != nil {
@@ -148,6 +192,8 @@ func (m *Manager) Start() {
 	m.CrontabWrkRun(m, defaults.IPLocal, 1) // will make local bean pool
 	m.ConnectRedis(m.IP())                  // will make local redis pool
 	m.ClearWrkRun(m, m.IP(), 1)             // get local redis pool
+	m.DelayWrkRun(m, defaults.IPLocal, 1)   // will make local redis pool
+	m.DispatchWrkRun(m, defaults.IPLocal, 1)
 
 	c := make(chan os.Signal)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
@@ -161,6 +207,11 @@ func (m *Manager) AddProtocolGenFn(v uint, fn ProtocolGenFn) {
 	m.protocolGenMap[v] = fn
 }
 
+// HasProtocol 是否已添加对应版本的协议
+func (m *Manager) HasProtocol(v uint) bool {
+	return m.protocolGenMap[v] != nil
+}
+
 // Inbox 转换成 inbox key
 func (m *Manager) Inbox(v interface{}) string {
 	return fmt.Sprintf("ms:inbox:%v", v)
@@ -171,11 +222,61 @@ func (m *Manager) Outbox(v interface{}) string {
 	return fmt.Sprintf("ms:outbox:%v", v)
 }
 
+// Processing 转换成 processing key（可靠模式下，已取出但未处理完成的消息）
+func (m *Manager) Processing(v interface{}) string {
+	return fmt.Sprintf("ms:processing:%v", v)
+}
+
 // CrontabName 返回配置crontabhash表名
 func (m *Manager) CrontabName() string {
 	return "ms:crontab:" + m.IP()
 }
 
+// DLQName 返回死信队列名
+func (m *Manager) DLQName() string {
+	return "ms:dlq:" + m.IP()
+}
+
+// RouteName 返回配置路由hash表名（ service => "ip1,ip2" ）
+func (m *Manager) RouteName() string {
+	return "ms:route"
+}
+
+// LimitName 返回配置限流hash表名（ name => "rate/burst|shared" ），共享令牌桶以此为前缀
+func (m *Manager) LimitName() string {
+	return "ms:limit"
+}
+
+// VersionName 返回配置协议版本hash表名（ inbox => version ）
+func (m *Manager) VersionName() string {
+	return "ms:version"
+}
+
+// ClaimName 返回大数据存取 key
+func (m *Manager) ClaimName(sum string) string {
+	return "ms:claim:" + sum
+}
+
+// DelayName 返回延迟请求sorted set名（ score 为投递时间 ）
+func (m *Manager) DelayName() string {
+	return "ms:delay"
+}
+
+// JobUniqueName 返回 Job 唯一键 key（ 值为 job id ）
+func (m *Manager) JobUniqueName(tube, unique string) string {
+	return "ms:job-unique:" + tube + ":" + unique
+}
+
+// RegistryName 返回服务注册hash表名（ service@host#pid => RegEntry json ）
+func (m *Manager) RegistryName() string {
+	return "ms:registry"
+}
+
+// DedupName 返回共享去重记录前缀
+func (m *Manager) DedupName() string {
+	return "ms:dedup"
+}
+
 // WaitAdd 加入等待组
 func (m *Manager) WaitAdd() {
 	m.waitGroupStop.Add(1)
@@ -211,6 +312,11 @@ func (m *Manager) IP() string {
 	return m.ip
 }
 
+// IsLocal ip 是否指向本机 broker
+func (m *Manager) IsLocal(ip string) bool {
+	return ip == "" || ip == defaults.IPLocal || ip == m.IP()
+}
+
 // ConnectRedis 链接指定IP，并启动相应的SubWorker（如果是第一次链接）
 func (m *Manager) ConnectRedis(ip string) (*rxpool.Pool, error) {
 	p, isNew, err := m.RedisPoolMap.FetchOrNew(ip, m.Conf.PoolSize)
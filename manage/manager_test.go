@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/chashu-code/micro-broker/defaults"
 	"github.com/chashu-code/micro-broker/utils"
@@ -52,6 +53,11 @@ func Test_Manager_Outbox(t *testing.T) {
 	assert.Equal(t, "ms:outbox:1", mgr.Outbox(1))
 }
 
+func Test_Manager_Processing(t *testing.T) {
+	mgr := newManager()
+	assert.Equal(t, "ms:processing:1", mgr.Processing(1))
+}
+
 func Test_Manager_Shutdown(t *testing.T) {
 	mgr := newManager()
 	assert.False(t, mgr.IsShutdown())
@@ -73,6 +79,24 @@ func Test_Manager_CrontabName(t *testing.T) {
 	assert.Equal(t, name, mgr.CrontabName())
 }
 
+func Test_Manager_DLQName(t *testing.T) {
+	mgr := newManager()
+	assert.Equal(t, "ms:dlq:"+mgr.IP(), mgr.DLQName())
+}
+
+func Test_Manager_RouteName(t *testing.T) {
+	mgr := newManager()
+	assert.Equal(t, "ms:route", mgr.RouteName())
+}
+
+func Test_Manager_IsLocal(t *testing.T) {
+	mgr := newManager()
+	assert.True(t, mgr.IsLocal(""))
+	assert.True(t, mgr.IsLocal(defaults.IPLocal))
+	assert.True(t, mgr.IsLocal(mgr.IP()))
+	assert.False(t, mgr.IsLocal("10.255.255.1"))
+}
+
 func Test_Manager_Pack(t *testing.T) {
 	mgr := newManager()
 	_, err := mgr.Pack(nil)
@@ -150,3 +174,16 @@ func Test_Manager_NextTID(t *testing.T) {
 	id := strings.Split(tid, "/")[2]
 	assert.Equal(t, "1", id)
 }
+
+func Test_Manager_Uptime(t *testing.T) {
+	mgr := newManager()
+	assert.True(t, mgr.Uptime() >= 0)
+	assert.True(t, mgr.Uptime() < time.Minute)
+}
+
+func Test_Manager_HasProtocol(t *testing.T) {
+	mgr := newManager()
+	assert.False(t, mgr.HasProtocol(1))
+	mgr.AddProtocolGenFn(1, genProtocol)
+	assert.True(t, mgr.HasProtocol(1))
+}
@@ -16,9 +16,51 @@ const (
 	ActRes = "res"
 	// ActJob Job推送
 	ActJob = "job"
+	// ActPing 存活检测，由 broker 直接应答 "pong"
+	ActPing = "ping"
+	// ActEcho 原样应答 Data，由 broker 直接应答
+	ActEcho = "echo"
+	// ActInfo broker 信息，由 broker 直接应答
+	ActInfo = "info"
+	// ActReg 服务注册（ 心跳 ），Data 为版本号
+	ActReg = "reg"
+	// ActUnreg 服务注销
+	ActUnreg = "unreg"
+	// ActJobDelete 删除 Job，Data 为 job id
+	ActJobDelete = "job.delete"
+	// ActJobKick 将 buried 或 delayed 的 Job 转为 ready，Data 为 job id
+	ActJobKick = "job.kick"
+	// ActJobPeek 查看 Job 内容，Data 为 job id
+	ActJobPeek = "job.peek"
+	// ActJobBury 搁置 Job，Data 为 job id，Code 同 ActJob（ 取其中的 pri ）
+	ActJobBury = "job.bury"
+	// ActJobStats 查看 Job 状态，Data 为 job id
+	ActJobStats = "job.stats"
+
+	// HeaderAuth 认证凭据，仅随请求传递，日志中隐去
+	HeaderAuth = "auth"
+	// HeaderContentType Data 的内容类型
+	HeaderContentType = "content-type"
+	// HeaderReplyTo 应答投递的 inbox（ 即 pid ），未设置则投递至 RID 中的 pid；随应答传递
+	HeaderReplyTo = "reply-to"
+	// HeaderBaggagePrefix 链路追踪信息的前缀，请求与应答均传递
+	HeaderBaggagePrefix = "baggage-"
+
+	// CodeBadRequest 请求参数错误的应答码
+	CodeBadRequest = "400"
+	// CodeNotFound 服务未注册或 Job 不存在的应答码
+	CodeNotFound = "404"
+	// CodeConflict 相同唯一键的 Job 正在 Put，稍后可重试的应答码
+	CodeConflict = "409"
+	// CodeTooMany 超出限流的应答码
+	CodeTooMany = "429"
+	// CodeUnavailable 目标不可用（ 熔断中 ）的应答码
+	CodeUnavailable = "503"
+	// CodeTimeout 请求已过期的应答码
+	CodeTimeout = "504"
 )
 
-// Msg 消息结构
+// Msg 消息结构，SendTime、DeadLine 为 unix 毫秒（ V1、V2、JSON 协议以秒传输 ）
 type Msg struct {
 	Action   string
 	BID      string
@@ -29,11 +71,30 @@ type Msg struct {
 	Nav      string
 	SendTime int64
 	DeadLine int64
+	// DeliverAt 请求延迟投递时间（ unix 秒 ），0 则立即投递
+	DeliverAt int64
+	// Headers 扩展元信息（ V2 协议起支持 ）
+	Headers map[string]string
+	// Job Job 选项（ V4 协议起支持 ），为 nil 时使用 Code 中的 "pri|delay|ttr"
+	Job *JobOpts
 
 	Data interface{}
 	Code string
 
 	V uint
+
+	// Ack 可靠模式下的确认信息（不参与序列化，Clone 不复制）
+	Ack *MsgAck
+}
+
+// MsgAck 可靠模式下，消息处理完成后需从 redis 处理中列表移除的信息
+type MsgAck struct {
+	// IP 来源 redis
+	IP string
+	// Key 处理中列表
+	Key string
+	// Raw 原始消息 bytes
+	Raw []byte
 }
 
 // MarshalLog zap log 序列化接口方法
@@ -47,12 +108,34 @@ func (msg *Msg) MarshalLog(kv zap.KeyValue) error {
 	kv.AddString("nav", msg.Nav)
 	kv.AddInt64("st", msg.SendTime)
 	kv.AddInt64("dl", msg.DeadLine)
+	if msg.DeliverAt > 0 {
+		kv.AddInt64("da", msg.DeliverAt)
+	}
+	if len(msg.Headers) > 0 {
+		kv.AddMarshaler("hdr", logHeaders(msg.Headers))
+	}
+	if msg.Job != nil {
+		kv.AddMarshaler("job", msg.Job)
+	}
 	kv.AddString("code", msg.Code)
 	kv.AddObject("data", msg.Data)
 
 	return nil
 }
 
+// logHeaders 记录 Headers，隐去认证凭据
+type logHeaders map[string]string
+
+func (h logHeaders) MarshalLog(kv zap.KeyValue) error {
+	for k, v := range h {
+		if k == HeaderAuth {
+			v = "***"
+		}
+		kv.AddString(k, v)
+	}
+	return nil
+}
+
 // Clone 克隆一个新的msg
 func (msg *Msg) Clone(action string) *Msg {
 	msgNew := &Msg{
@@ -70,7 +153,10 @@ func (msg *Msg) Clone(action string) *Msg {
 		msgNew.Topic = msg.Topic
 		msgNew.Channel = msg.Channel
 		msgNew.Nav = msg.Nav
+		msgNew.DeliverAt = msg.DeliverAt
+		msgNew.Headers = msg.cloneHeaders(true)
 	} else {
+		msgNew.Headers = msg.cloneHeaders(false)
 		if msg.Code == "" {
 			msgNew.Code = "0"
 		} else {
@@ -81,6 +167,21 @@ func (msg *Msg) Clone(action string) *Msg {
 	return msgNew
 }
 
+// cloneHeaders 复制 Headers，应答仅复制链路追踪信息及 reply-to
+func (msg *Msg) cloneHeaders(all bool) map[string]string {
+	var hdrs map[string]string
+	for k, v := range msg.Headers {
+		if !all && k != HeaderReplyTo && !strings.HasPrefix(k, HeaderBaggagePrefix) {
+			continue
+		}
+		if hdrs == nil {
+			hdrs = make(map[string]string, len(msg.Headers))
+		}
+		hdrs[k] = v
+	}
+	return hdrs
+}
+
 // TubeName 返回Job TubeName
 func (msg *Msg) TubeName() string {
 	return msg.comboName("-")
@@ -118,9 +219,92 @@ func (msg *Msg) FillWithReq(mgr *Manager) {
 	}
 }
 
+// IsDue 是否已到投递时间？
+func (msg *Msg) IsDue() bool {
+	return msg.DeliverAt <= time.Now().Unix()
+}
+
 // IsDead 是否已过期？
 func (msg *Msg) IsDead() bool {
-	return msg.DeadLine < time.Now().Unix()
+	return msg.DeadLine < NowMs()
+}
+
+// Remaining 距 DeadLine 的剩余时间，已过期返回 0
+func (msg *Msg) Remaining() time.Duration {
+	ms := msg.DeadLine - NowMs()
+	if ms < 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// SetTimeout 以当前时间为 SendTime，设置 timeout 后的 DeadLine
+func (msg *Msg) SetTimeout(timeout time.Duration) {
+	msg.SendTime = NowMs()
+	msg.DeadLine = msg.SendTime + int64(timeout/time.Millisecond)
+}
+
+// NowMs 当前 unix 毫秒
+func NowMs() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// JobID Data 转换为 job id
+func (msg *Msg) JobID() (uint64, error) {
+	switch v := msg.Data.(type) {
+	case uint64:
+		return v, nil
+	case int64:
+		if v > 0 {
+			return uint64(v), nil
+		}
+	case int:
+		if v > 0 {
+			return uint64(v), nil
+		}
+	case float64:
+		if v > 0 && v == float64(uint64(v)) {
+			return uint64(v), nil
+		}
+	case string:
+		return strconv.ParseUint(v, 10, 64)
+	case []byte:
+		return strconv.ParseUint(string(v), 10, 64)
+	}
+	return 0, fmt.Errorf("Error job id: %v", msg.Data)
+}
+
+// JobTube 返回 Put Job 的 tube，Job 选项中指定时优先
+func (msg *Msg) JobTube() string {
+	if msg.Job != nil && msg.Job.Tube != "" {
+		return msg.Job.Tube
+	}
+	return msg.TubeName()
+}
+
+// PutArgs 返回 Put Job 的相关参数：未设置 Job 选项时同 CodeToPutArgs；
+// 否则 Job 选项中未设置的部分取 Code 中的值，Code 或 Job 选项有误时返回 error
+func (msg *Msg) PutArgs() (pri uint32, delay, ttr time.Duration, err error) {
+	pri, delay, ttr, err = msg.CodeToPutArgs()
+	if msg.Job == nil || err != nil {
+		return
+	}
+
+	err = msg.Job.Validate()
+	if err != nil {
+		return
+	}
+
+	if msg.Job.Pri != nil {
+		pri = uint32(*msg.Job.Pri)
+	}
+	if msg.Job.Delay != nil {
+		delay = time.Duration(*msg.Job.Delay) * time.Second
+	}
+	if msg.Job.TTR != nil {
+		ttr = time.Duration(*msg.Job.TTR) * time.Second
+	}
+	return
 }
 
 // CodeToPutArgs Code 转换为 Put Job 的相关参数
@@ -1,63 +1,191 @@
 package manage
 
-import "time"
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// LaneRes 应答通道，优先级最高
+	LaneRes = iota
+	// LaneReq 请求通道
+	LaneReq
+	// LaneJob Job 通道，优先级最低
+	LaneJob
+
+	msgLaneCount = 3
+
+	// msgLaneRotate 每 msgLaneRotate 次 Pop，轮换一次最先检查的通道，避免低优先级通道饿死
+	msgLaneRotate = 8
+)
 
 // MsgChan 消息 Channel
 type MsgChan chan *Msg
 
-// MsgQueue  存储 channel 和 超时信息
+// MsgLaneFn 返回消息所属的优先级通道
+type MsgLaneFn func(msg *Msg) int
+
+// MsgQueue 按优先级分通道的消息队列，容量为所有通道共享
 type MsgQueue struct {
-	C          MsgChan
 	DurTimeout time.Duration
+	// LaneFn 消息所属通道，默认按 Action 区分
+	LaneFn MsgLaneFn
+
+	lanes [msgLaneCount]MsgChan
+	slots chan struct{}
+
+	popLock  *sync.Mutex
+	popCount int
+	rotate   int
 }
 
 // NewMsgQueueWithSize 构造一个 MsgQueue，可以指定缓冲大小
 func NewMsgQueueWithSize(msTimeout int, size int) *MsgQueue {
-	mq := make(MsgChan, size)
 	durationTimeout := time.Millisecond * time.Duration(msTimeout)
 
 	q := &MsgQueue{
-		C:          mq,
 		DurTimeout: durationTimeout,
+		LaneFn:     MsgLane,
+		slots:      make(chan struct{}, size),
+		popLock:    new(sync.Mutex),
+	}
+
+	for i := range q.lanes {
+		q.lanes[i] = make(MsgChan, size)
 	}
 	return q
 }
 
+// MsgLane 按 Action 区分通道：res > req > job
+func MsgLane(msg *Msg) int {
+	switch msg.Action {
+	case ActRes:
+		return LaneRes
+	case ActJob:
+		return LaneJob
+	default:
+		return LaneReq
+	}
+}
+
+func (q *MsgQueue) lane(msg *Msg) MsgChan {
+	i := q.LaneFn(msg)
+	if i < 0 || i >= msgLaneCount {
+		i = LaneReq
+	}
+	return q.lanes[i]
+}
+
 // Push 添加一个队列成员
 func (q *MsgQueue) Push(msg *Msg, isBlock bool) bool {
 	if isBlock {
 		select {
-		case q.C <- msg:
-			return true
+		case q.slots <- struct{}{}:
 		case <-time.After(q.DurTimeout):
 			return false
 		}
 	} else {
 		select {
-		case q.C <- msg:
-			return true
+		case q.slots <- struct{}{}:
 		default:
 			return false
 		}
 	}
 
+	// 已占用容量，通道不会阻塞
+	q.lane(msg) <- msg
+	return true
 }
 
-// Pop 返回一个队列成员
+// Pop 返回一个队列成员，优先返回高优先级通道的成员
 func (q *MsgQueue) Pop(isBlock bool) (*Msg, bool) {
-	if isBlock {
-		select {
-		case item := <-q.C:
-			return item, true
-		case <-time.After(q.DurTimeout):
-			return nil, false
-		}
-	} else {
+	if !isBlock {
+		return q.pop(nil)
+	}
+	return q.pop(time.After(q.DurTimeout))
+}
+
+// pop 按优先级获取，timeout 为 nil 则不阻塞
+func (q *MsgQueue) pop(timeout <-chan time.Time) (*Msg, bool) {
+	if msg, ok := q.popPriority(); ok {
+		return msg, true
+	}
+
+	if timeout == nil {
+		return nil, false
+	}
+
+	var msg *Msg
+	select {
+	case msg = <-q.lanes[LaneRes]:
+	case msg = <-q.lanes[LaneReq]:
+	case msg = <-q.lanes[LaneJob]:
+	case <-timeout:
+		return nil, false
+	}
+
+	<-q.slots
+	return msg, true
+}
+
+// popPriority 非阻塞地按优先级获取，每 msgLaneRotate 次轮换一次起始通道
+func (q *MsgQueue) popPriority() (*Msg, bool) {
+	q.popLock.Lock()
+	q.popCount++
+	start := 0
+	if q.popCount%msgLaneRotate == 0 {
+		q.rotate = (q.rotate + 1) % msgLaneCount
+		start = q.rotate
+	}
+	q.popLock.Unlock()
+
+	for i := 0; i < msgLaneCount; i++ {
 		select {
-		case item := <-q.C:
-			return item, true
+		case msg := <-q.lanes[(start+i)%msgLaneCount]:
+			<-q.slots
+			return msg, true
 		default:
-			return nil, false
 		}
 	}
+
+	return nil, false
+}
+
+// Len 队列成员数量
+func (q *MsgQueue) Len() int {
+	return len(q.slots)
+}
+
+// IsFull 队列是否已满
+func (q *MsgQueue) IsFull() bool {
+	return len(q.slots) >= cap(q.slots)
+}
+
+// Vacant 队列剩余空位数量
+func (q *MsgQueue) Vacant() int {
+	return cap(q.slots) - len(q.slots)
+}
+
+// PopBatch 阻塞获取首个成员后，在 wait 时间内继续获取，至多 max 个；超时返回 nil
+func (q *MsgQueue) PopBatch(max int, wait time.Duration) []*Msg {
+	msg, ok := q.Pop(true)
+	if !ok {
+		return nil
+	}
+
+	msgs := []*Msg{msg}
+
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timeout = time.After(wait)
+	}
+
+	for len(msgs) < max {
+		if msg, ok = q.pop(timeout); !ok {
+			break
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs
 }
@@ -3,6 +3,7 @@ package manage
 import (
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -58,3 +59,97 @@ func Test_MsgQueue_Empty_And_Full(t *testing.T) {
 
 	}
 }
+
+func Test_MsgQueue_IsFull(t *testing.T) {
+	q := NewMsgQueueWithSize(1, 1)
+	assert.False(t, q.IsFull())
+	q.Push(&Msg{}, false)
+	assert.True(t, q.IsFull())
+	q.Pop(false)
+	assert.False(t, q.IsFull())
+}
+
+func Test_MsgQueue_PopBatch(t *testing.T) {
+	q := NewMsgQueueWithSize(1, 5)
+	assert.Equal(t, 5, q.Vacant())
+	assert.Nil(t, q.PopBatch(3, 0))
+
+	for i := 0; i < 4; i++ {
+		q.Push(&Msg{Nav: strconv.Itoa(i)}, false)
+	}
+	assert.Equal(t, 1, q.Vacant())
+
+	msgs := q.PopBatch(3, 0)
+	assert.Equal(t, 3, len(msgs))
+	assert.Equal(t, "0", msgs[0].Nav)
+	assert.Equal(t, "2", msgs[2].Nav)
+
+	// 等待期间推入的成员一并返回
+	go func() {
+		time.Sleep(time.Millisecond)
+		q.Push(&Msg{Nav: "4"}, false)
+	}()
+	msgs = q.PopBatch(3, 50*time.Millisecond)
+	assert.Equal(t, 2, len(msgs))
+	assert.Equal(t, "4", msgs[1].Nav)
+}
+
+func Test_MsgQueue_Lane(t *testing.T) {
+	q := NewMsgQueueWithSize(1, 30)
+	for _, act := range []string{ActJob, ActReq, ActRes} {
+		q.Push(&Msg{Action: act}, false)
+	}
+	assert.Equal(t, 3, q.Len())
+
+	// 高优先级通道先出
+	for _, act := range []string{ActRes, ActReq, ActJob} {
+		msg, ok := q.Pop(false)
+		assert.True(t, ok)
+		assert.Equal(t, act, msg.Action)
+	}
+	assert.Equal(t, 0, q.Len())
+
+	// 自定义通道
+	q.LaneFn = func(msg *Msg) int {
+		if msg.Topic == "vip" {
+			return LaneRes
+		}
+		return LaneJob
+	}
+	q.Push(&Msg{Action: ActRes}, false)
+	q.Push(&Msg{Action: ActReq, Topic: "vip"}, false)
+	msg, _ := q.Pop(false)
+	assert.Equal(t, "vip", msg.Topic)
+}
+
+func Test_MsgQueue_LaneStarve(t *testing.T) {
+	q := NewMsgQueueWithSize(1, 30)
+	q.Push(&Msg{Action: ActJob}, false)
+	for i := 0; i < 20; i++ {
+		q.Push(&Msg{Action: ActRes}, false)
+	}
+
+	// 高优先级通道持续有成员时，低优先级通道仍会被处理
+	msgs := q.PopBatch(msgLaneRotate*msgLaneCount, 0)
+	pos := -1
+	for i, msg := range msgs {
+		if msg.Action == ActJob {
+			pos = i
+		}
+	}
+	assert.True(t, pos > 0)
+	assert.True(t, pos < msgLaneRotate*msgLaneCount)
+}
+
+func Test_MsgQueue_PopBlockLane(t *testing.T) {
+	q := NewMsgQueueWithSize(50, 1)
+	go func() {
+		time.Sleep(time.Millisecond)
+		q.Push(&Msg{Action: ActJob}, false)
+	}()
+
+	msg, ok := q.Pop(true)
+	assert.True(t, ok)
+	assert.Equal(t, ActJob, msg.Action)
+	assert.False(t, q.IsFull())
+}
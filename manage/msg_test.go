@@ -38,14 +38,75 @@ func Test_Msg_IsDead(t *testing.T) {
 
 	assert.True(t, msg.IsDead())
 
-	now := time.Now().Unix()
+	// 毫秒精度
+	now := NowMs()
 	msg.DeadLine = now - 1
 	assert.True(t, msg.IsDead())
-	msg.DeadLine = now
+	msg.DeadLine = now + 500
+	assert.False(t, msg.IsDead())
+	msg.DeadLine = now + 1000
 	assert.False(t, msg.IsDead())
-	msg.DeadLine = now + 1
+}
+
+func Test_Msg_Remaining(t *testing.T) {
+	msg := &Msg{}
+	assert.Equal(t, time.Duration(0), msg.Remaining())
+
+	msg.SetTimeout(300 * time.Millisecond)
+	assert.Equal(t, int64(300), msg.DeadLine-msg.SendTime)
+	assert.True(t, msg.Remaining() > 200*time.Millisecond)
+	assert.True(t, msg.Remaining() <= 300*time.Millisecond)
 	assert.False(t, msg.IsDead())
 
+	msg.DeadLine = NowMs() - 1
+	assert.Equal(t, time.Duration(0), msg.Remaining())
+}
+
+func Test_Msg_IsDue(t *testing.T) {
+	msg := &Msg{}
+	assert.True(t, msg.IsDue())
+
+	now := time.Now().Unix()
+	msg.DeliverAt = now
+	assert.True(t, msg.IsDue())
+	msg.DeliverAt = now + 10
+	assert.False(t, msg.IsDue())
+}
+
+func Test_Msg_JobID(t *testing.T) {
+	for _, data := range []interface{}{uint64(12), int64(12), 12, float64(12), "12", []byte("12")} {
+		id, err := (&Msg{Data: data}).JobID()
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(12), id)
+	}
+
+	for _, data := range []interface{}{nil, int64(0), -1, 1.5, "x", true} {
+		_, err := (&Msg{Data: data}).JobID()
+		assert.NotNil(t, err)
+	}
+}
+
+func Test_Msg_CloneHeaders(t *testing.T) {
+	msg := &Msg{
+		Headers: map[string]string{
+			HeaderAuth:                  "token",
+			HeaderContentType:           "json",
+			HeaderBaggagePrefix + "uid": "7",
+			HeaderReplyTo:               "9",
+		},
+	}
+
+	// 请求复制全部 Headers
+	msgReq := msg.Clone(ActReq)
+	assert.Equal(t, msg.Headers, msgReq.Headers)
+	msgReq.Headers["x"] = "y"
+	assert.NotContains(t, msg.Headers, "x")
+
+	// 应答仅复制链路追踪信息及 reply-to
+	msgRes := msg.Clone(ActRes)
+	assert.Equal(t, map[string]string{HeaderBaggagePrefix + "uid": "7", HeaderReplyTo: "9"}, msgRes.Headers)
+
+	assert.Nil(t, (&Msg{}).Clone(ActReq).Headers)
 }
 
 func Test_Msg_TubeName(t *testing.T) {
@@ -92,20 +153,23 @@ func Test_Msg_Clone(t *testing.T) {
 	now := time.Now().Unix()
 
 	msg := &Msg{
-		Topic:    "topic",
-		Channel:  "channel",
-		Nav:      "nav",
-		Action:   "req",
-		BID:      "b",
-		RID:      "r",
-		TID:      "t",
-		Data:     "data",
-		SendTime: now,
-		DeadLine: now + 1,
-		V:        1,
+		Topic:     "topic",
+		Channel:   "channel",
+		Nav:       "nav",
+		Action:    "req",
+		BID:       "b",
+		RID:       "r",
+		TID:       "t",
+		Data:      "data",
+		SendTime:  now,
+		DeadLine:  now + 1,
+		DeliverAt: now,
+		V:         1,
+		Ack:       &MsgAck{},
 	}
 
 	msgRes := msg.Clone(ActRes)
+	assert.Nil(t, msgRes.Ack)
 	assert.Equal(t, ActRes, msgRes.Action)
 	assert.Empty(t, msgRes.Topic)
 	assert.Empty(t, msgRes.Channel)
@@ -125,6 +189,7 @@ func Test_Msg_Clone(t *testing.T) {
 	assert.Equal(t, msg.Topic, msgReq.Topic)
 	assert.Equal(t, msg.Channel, msgReq.Channel)
 	assert.Equal(t, msg.Nav, msgReq.Nav)
+	assert.Equal(t, msg.DeliverAt, msgReq.DeliverAt)
 	assert.Empty(t, msg.Code)
 	assert.Equal(t, msg.Data, msgRes.Data)
 	assert.Equal(t, msg.BID, msgRes.BID)
@@ -134,3 +199,38 @@ func Test_Msg_Clone(t *testing.T) {
 	assert.Equal(t, msg.DeadLine, msgRes.DeadLine)
 	assert.Equal(t, msg.V, msgRes.V)
 }
+
+func Test_Msg_PutArgs(t *testing.T) {
+	i64 := func(v int64) *int64 { return &v }
+
+	// 无 Job 选项，同 CodeToPutArgs
+	msg := &Msg{Topic: "test", Channel: "c", Code: "2|3|4"}
+	pri, delay, ttr, err := msg.PutArgs()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), pri)
+	assert.Equal(t, "test-c", msg.JobTube())
+
+	// Job 选项优先，未设置的部分取 Code 中的值
+	msg.Job = &JobOpts{Pri: i64(0), TTR: i64(60), Tube: "other"}
+	pri, delay, ttr, err = msg.PutArgs()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), pri)
+	assert.Equal(t, 3*time.Second, delay)
+	assert.Equal(t, time.Minute, ttr)
+	assert.Equal(t, "other", msg.JobTube())
+
+	msg.Code = ""
+	_, delay, _, _ = msg.PutArgs()
+	assert.Equal(t, time.Duration(0), delay)
+
+	// 选项有误
+	msg.Job = &JobOpts{TTR: i64(0)}
+	_, _, _, err = msg.PutArgs()
+	assert.Contains(t, err.Error(), "Error job ttr")
+
+	// Code 有误，不因 Job 选项有效而忽略
+	msg.Job = &JobOpts{TTR: i64(60)}
+	msg.Code = "2|x|4"
+	_, _, _, err = msg.PutArgs()
+	assert.Error(t, err)
+}
@@ -0,0 +1,137 @@
+package manage
+
+import "sync"
+
+const (
+	// PendingUnknown 无记录（ 非本机投递的请求，或记录已清理 ）
+	PendingUnknown = ""
+	// PendingAnswered 已应答
+	PendingAnswered = "answered"
+	// PendingExpired 已过期（ 已应答超时 ）
+	PendingExpired = "expired"
+
+	// pendingKeepSecs 已完成请求的保留时间（ 自 DeadLine 起 ），用于识别重复或迟到的应答
+	pendingKeepSecs = 60
+)
+
+// PendingReq 等待应答的请求
+type PendingReq struct {
+	// Msg 请求消息
+	Msg *Msg
+	// Node 路由节点，投递本机时为 nil
+	Node *RouteNode
+}
+
+// Pending 等待应答请求表，以 RID + TID 为键
+type Pending struct {
+	lock   *sync.Mutex
+	reqMap map[string]*PendingReq
+	// finMap 已完成的请求，key => 状态及清理时间
+	finMap map[string]*pendingFin
+}
+
+type pendingFin struct {
+	state    string
+	expireAt int64
+}
+
+// NewPending 构建空的等待应答请求表
+func NewPending() *Pending {
+	return &Pending{
+		lock:   new(sync.Mutex),
+		reqMap: make(map[string]*PendingReq),
+		finMap: make(map[string]*pendingFin),
+	}
+}
+
+func pendingKey(msg *Msg) string {
+	return msg.RID + "@" + msg.TID
+}
+
+// Add 记录已投递的请求
+func (p *Pending) Add(msg *Msg, node *RouteNode) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := pendingKey(msg)
+	delete(p.finMap, key)
+	p.reqMap[key] = &PendingReq{
+		Msg:  msg,
+		Node: node,
+	}
+}
+
+// Get 以应答查找对应的请求（ 不移除 ），未找到返回 nil
+func (p *Pending) Get(msgRes *Msg) *PendingReq {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.reqMap[pendingKey(msgRes)]
+}
+
+// Done 以应答移除对应的请求，返回该请求，未找到返回 nil
+func (p *Pending) Done(msgRes *Msg) *PendingReq {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := pendingKey(msgRes)
+	req := p.reqMap[key]
+	if req != nil {
+		delete(p.reqMap, key)
+		p.finish(key, req, PendingAnswered)
+	}
+	return req
+}
+
+// State 返回应答对应请求的完成状态（ 用于 Done 未找到请求时，区分重复或迟到的应答 ）
+func (p *Pending) State(msgRes *Msg) string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if fin := p.finMap[pendingKey(msgRes)]; fin != nil {
+		return fin.state
+	}
+	return PendingUnknown
+}
+
+func (p *Pending) finish(key string, req *PendingReq, state string) {
+	expireAt := req.Msg.DeadLine
+	if now := NowMs(); expireAt < now {
+		expireAt = now
+	}
+
+	p.finMap[key] = &pendingFin{
+		state:    state,
+		expireAt: expireAt + pendingKeepSecs*1000,
+	}
+}
+
+// Expire 移除并返回所有 DeadLine 早于 now（ unix 毫秒 ）的请求，同时清理超出保留时间的完成记录
+func (p *Pending) Expire(now int64) []*PendingReq {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for key, fin := range p.finMap {
+		if fin.expireAt < now {
+			delete(p.finMap, key)
+		}
+	}
+
+	reqs := []*PendingReq{}
+	for key, req := range p.reqMap {
+		if req.Msg.DeadLine < now {
+			reqs = append(reqs, req)
+			delete(p.reqMap, key)
+			p.finish(key, req, PendingExpired)
+		}
+	}
+	return reqs
+}
+
+// Len 等待应答的请求数量
+func (p *Pending) Len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.reqMap)
+}
@@ -0,0 +1,69 @@
+package manage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Pending_AddDone(t *testing.T) {
+	p := NewPending()
+	msg := &Msg{RID: "1|a", TID: "t", DeadLine: 10}
+	node := &RouteNode{IP: "10.0.0.1"}
+
+	p.Add(msg, node)
+	assert.Equal(t, 1, p.Len())
+
+	// 不匹配
+	assert.Nil(t, p.Done(&Msg{RID: "1|a", TID: "x"}))
+	assert.Nil(t, p.Get(&Msg{RID: "1|a", TID: "x"}))
+
+	// 查找不移除
+	assert.Equal(t, msg, p.Get(msg.Clone(ActRes)).Msg)
+	assert.Equal(t, 1, p.Len())
+
+	req := p.Done(msg.Clone(ActRes))
+	assert.Equal(t, msg, req.Msg)
+	assert.Equal(t, node, req.Node)
+	assert.Equal(t, 0, p.Len())
+
+	// 重复应答
+	assert.Nil(t, p.Done(msg.Clone(ActRes)))
+}
+
+func Test_Pending_Expire(t *testing.T) {
+	p := NewPending()
+	p.Add(&Msg{RID: "1|a", DeadLine: 10}, nil)
+	p.Add(&Msg{RID: "1|b", DeadLine: 11}, nil)
+
+	assert.Empty(t, p.Expire(10))
+
+	reqs := p.Expire(11)
+	assert.Equal(t, 1, len(reqs))
+	assert.Equal(t, "1|a", reqs[0].Msg.RID)
+	assert.Equal(t, 1, p.Len())
+}
+
+func Test_Pending_State(t *testing.T) {
+	p := NewPending()
+	now := NowMs()
+	msgA := &Msg{RID: "1|a", DeadLine: now + 10000}
+	msgB := &Msg{RID: "1|b", DeadLine: now - 1}
+
+	p.Add(msgA, nil)
+	p.Add(msgB, nil)
+	assert.Equal(t, PendingUnknown, p.State(msgA))
+
+	p.Done(msgA.Clone(ActRes))
+	p.Expire(now)
+	assert.Equal(t, PendingAnswered, p.State(msgA.Clone(ActRes)))
+	assert.Equal(t, PendingExpired, p.State(msgB.Clone(ActRes)))
+	assert.Equal(t, PendingUnknown, p.State(&Msg{RID: "1|c"}))
+
+	// 超出保留时间后清理
+	p.Expire(now + pendingKeepSecs*1000 + 1000)
+	assert.Equal(t, PendingAnswered, p.State(msgA))
+	p.Expire(now + 10000 + pendingKeepSecs*1000 + 1000)
+	assert.Equal(t, PendingUnknown, p.State(msgA))
+	assert.Equal(t, PendingUnknown, p.State(msgB))
+}
@@ -0,0 +1,267 @@
+package manage
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	rxpool "github.com/mediocregopher/radix.v2/pool"
+	"github.com/mediocregopher/radix.v2/util"
+)
+
+// luaHdelIfSame 字段值未变（ 期间无新的心跳 ）时才删除，ARGV: field, value
+const luaHdelIfSame = `
+if redis.call('hget', KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call('hdel', KEYS[1], ARGV[1])
+end
+return 0
+`
+
+// RegEntry 服务注册信息
+type RegEntry struct {
+	// Service topic/channel 或 topic
+	Service string `json:"service"`
+	// Host 服务所在 broker ip
+	Host    string `json:"host"`
+	Pid     string `json:"pid"`
+	Version string `json:"version,omitempty"`
+	// Proto 服务实例使用的协议版本（ 即注册消息的协议版本 ）
+	Proto uint `json:"proto,omitempty"`
+	// ExpireAt 心跳过期时间（ unix 秒 ）
+	ExpireAt int64 `json:"expire_at"`
+}
+
+// NewRegEntry 以注册消息构造注册信息，Data 可为版本号或 {"version": "x"}
+func NewRegEntry(msg *Msg, ttlSecs int) (*RegEntry, error) {
+	pid, err := msg.PidOfRID()
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Topic == "" {
+		return nil, errors.New("register need topic")
+	}
+
+	e := &RegEntry{
+		Service:  msg.ServiceName(),
+		Host:     msg.BID,
+		Pid:      pid,
+		Proto:    msg.V,
+		ExpireAt: time.Now().Unix() + int64(ttlSecs),
+	}
+
+	switch data := msg.Data.(type) {
+	case string:
+		e.Version = data
+	case map[string]interface{}:
+		e.Version, _ = data["version"].(string)
+	}
+
+	return e, nil
+}
+
+// Key 注册信息在 hash 表中的字段名
+func (e *RegEntry) Key() string {
+	return e.Service + "@" + e.Host + "#" + e.Pid
+}
+
+// Registry 服务注册表：存放于配置 redis hash 表（ 字段 => RegEntry json ），本机保留一份视图
+type Registry struct {
+	mgr *Manager
+
+	lock     *sync.RWMutex
+	entryMap map[string][]*RegEntry
+	cursor   int
+}
+
+// NewRegistry 构建空的服务注册表
+func NewRegistry(mgr *Manager) *Registry {
+	return &Registry{
+		mgr:      mgr,
+		lock:     new(sync.RWMutex),
+		entryMap: make(map[string][]*RegEntry),
+	}
+}
+
+func (r *Registry) pool() (*rxpool.Pool, error) {
+	if r.mgr.RedisPoolMap == nil {
+		return nil, errors.New("redis pool map unset")
+	}
+
+	p, _, err := r.mgr.RedisPoolMap.FetchOrNew(r.mgr.Conf.IPConf, r.mgr.Conf.PoolSize)
+	return p, err
+}
+
+// Register 注册或续期，同时更新本机视图
+func (r *Registry) Register(e *RegEntry) error {
+	bts, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	p, err := r.pool()
+	if err != nil {
+		return err
+	}
+
+	if res := p.Cmd("hset", r.mgr.RegistryName(), e.Key(), bts); res.Err != nil {
+		return res.Err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entries := r.entryMap[e.Service]
+	for i, item := range entries {
+		if item.Key() == e.Key() {
+			entries[i] = e
+			return nil
+		}
+	}
+	r.entryMap[e.Service] = append(entries, e)
+	return nil
+}
+
+// Unregister 注销，同时更新本机视图
+func (r *Registry) Unregister(e *RegEntry) error {
+	p, err := r.pool()
+	if err != nil {
+		return err
+	}
+
+	if res := p.Cmd("hdel", r.mgr.RegistryName(), e.Key()); res.Err != nil {
+		return res.Err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entries := []*RegEntry{}
+	for _, item := range r.entryMap[e.Service] {
+		if item.Key() != e.Key() {
+			entries = append(entries, item)
+		}
+	}
+	r.entryMap[e.Service] = entries
+	return nil
+}
+
+// Load 从配置 redis 加载注册表，并清理心跳已过期的注册信息，返回有效数量
+func (r *Registry) Load() (int, error) {
+	p, err := r.pool()
+	if err != nil {
+		return 0, err
+	}
+
+	name := r.mgr.RegistryName()
+	mp, err := p.Cmd("hgetall", name).Map()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().Unix()
+	count := 0
+	entryMap := make(map[string][]*RegEntry)
+
+	for key, str := range mp {
+		e := &RegEntry{}
+		if err := json.Unmarshal([]byte(str), e); err != nil || e.ExpireAt < now {
+			util.LuaEval(p, luaHdelIfSame, 1, name, key, str)
+			continue
+		}
+		entryMap[e.Service] = append(entryMap[e.Service], e)
+		count++
+	}
+
+	r.lock.Lock()
+	r.entryMap = entryMap
+	r.lock.Unlock()
+	return count, nil
+}
+
+func (r *Registry) alive(name string, now int64) []*RegEntry {
+	entries := []*RegEntry{}
+	for _, e := range r.entryMap[name] {
+		if e.ExpireAt >= now {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Lookup 返回 msg 对应服务的有效注册信息，优先匹配 ServiceName，其次 Topic
+func (r *Registry) Lookup(msg *Msg) []*RegEntry {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	now := time.Now().Unix()
+	for _, name := range []string{msg.ServiceName(), msg.Topic} {
+		if entries := r.alive(name, now); len(entries) > 0 {
+			return entries
+		}
+	}
+	return nil
+}
+
+// IsRegistered msg 对应的服务是否已注册
+func (r *Registry) IsRegistered(msg *Msg) bool {
+	return len(r.Lookup(msg)) > 0
+}
+
+// Pick 轮流返回注册了 msg 对应服务的 broker ip，未注册返回 ""
+func (r *Registry) Pick(msg *Msg) string {
+	entries := r.Lookup(msg)
+	if len(entries) == 0 {
+		return ""
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.cursor++
+	return entries[r.cursor%len(entries)].Host
+}
+
+// Proto 返回 topic（ 含其下各 channel ）有效注册实例中最低的协议版本，以便所有实例都能解析；未知返回 0
+func (r *Registry) Proto(topic string) uint {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	now := time.Now().Unix()
+	v := uint(0)
+	for name := range r.entryMap {
+		if name != topic && !strings.HasPrefix(name, topic+"/") {
+			continue
+		}
+		for _, e := range r.alive(name, now) {
+			if e.Proto > 0 && (v == 0 || e.Proto < v) {
+				v = e.Proto
+			}
+		}
+	}
+	return v
+}
+
+// List 返回所有有效注册信息（ 按 Key 排序 ）
+func (r *Registry) List() []*RegEntry {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	now := time.Now().Unix()
+	entries := []*RegEntry{}
+	for name := range r.entryMap {
+		entries = append(entries, r.alive(name, now)...)
+	}
+
+	sort.Sort(regEntries(entries))
+	return entries
+}
+
+type regEntries []*RegEntry
+
+func (es regEntries) Len() int           { return len(es) }
+func (es regEntries) Less(i, j int) bool { return es[i].Key() < es[j].Key() }
+func (es regEntries) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
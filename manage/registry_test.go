@@ -0,0 +1,123 @@
+package manage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chashu-code/micro-broker/defaults"
+	"github.com/chashu-code/micro-broker/pool"
+	"github.com/mediocregopher/radix.v2/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RegEntry_New(t *testing.T) {
+	_, err := NewRegEntry(&Msg{Topic: "user"}, 10)
+	assert.NotNil(t, err)
+
+	_, err = NewRegEntry(&Msg{RID: "100|a"}, 10)
+	assert.Contains(t, err.Error(), "topic")
+
+	msg := &Msg{Topic: "user", Channel: "login", BID: "10.0.0.1", RID: "100|a", Data: "1.2"}
+	e, err := NewRegEntry(msg, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, "user/login", e.Service)
+	assert.Equal(t, "10.0.0.1", e.Host)
+	assert.Equal(t, "100", e.Pid)
+	assert.Equal(t, "1.2", e.Version)
+	assert.Equal(t, uint(0), e.Proto)
+	assert.Equal(t, "user/login@10.0.0.1#100", e.Key())
+	assert.True(t, e.ExpireAt > time.Now().Unix())
+
+	msg.Data = map[string]interface{}{"version": "1.3"}
+	e, _ = NewRegEntry(msg, 10)
+	assert.Equal(t, "1.3", e.Version)
+}
+
+func Test_Registry_Lookup(t *testing.T) {
+	r := NewRegistry(nil)
+	now := time.Now().Unix()
+	r.entryMap = map[string][]*RegEntry{
+		"user":       {{Service: "user", Host: "10.0.0.1", Pid: "1", ExpireAt: now + 10}},
+		"user/login": {{Service: "user/login", Host: "10.0.0.2", Pid: "1", ExpireAt: now - 1}},
+		"order": {
+			{Service: "order", Host: "10.0.0.3", Pid: "1", ExpireAt: now + 10},
+			{Service: "order", Host: "10.0.0.4", Pid: "1", ExpireAt: now + 10},
+		},
+	}
+
+	// 心跳过期则忽略，匹配 Topic
+	msg := &Msg{Topic: "user", Channel: "login"}
+	assert.True(t, r.IsRegistered(msg))
+	assert.Equal(t, "10.0.0.1", r.Pick(msg))
+
+	assert.False(t, r.IsRegistered(&Msg{Topic: "unknown"}))
+	assert.Equal(t, "", r.Pick(&Msg{Topic: "unknown"}))
+
+	// 轮流返回
+	msg = &Msg{Topic: "order"}
+	assert.NotEqual(t, r.Pick(msg), r.Pick(msg))
+
+	entries := r.List()
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, "order", entries[0].Service)
+}
+
+func Test_Registry_Proto(t *testing.T) {
+	r := NewRegistry(nil)
+	now := time.Now().Unix()
+	r.entryMap = map[string][]*RegEntry{
+		"user/login": {{Service: "user/login", Host: "10.0.0.1", Pid: "1", Proto: 2, ExpireAt: now + 10}},
+		"user":       {{Service: "user", Host: "10.0.0.2", Pid: "1", ExpireAt: now + 10}},
+		"users":      {{Service: "users", Host: "10.0.0.3", Pid: "1", Proto: 1, ExpireAt: now + 10}},
+		"order": {
+			{Service: "order", Host: "10.0.0.1", Pid: "1", Proto: 1, ExpireAt: now - 1},
+			{Service: "order", Host: "10.0.0.2", Pid: "1", Proto: 5, ExpireAt: now + 10},
+			{Service: "order", Host: "10.0.0.3", Pid: "1", Proto: 2, ExpireAt: now + 10},
+		},
+	}
+
+	// 取有效实例中最低的版本，含 channel，忽略未知版本及过期实例
+	assert.Equal(t, uint(2), r.Proto("user"))
+	assert.Equal(t, uint(2), r.Proto("order"))
+	assert.Equal(t, uint(0), r.Proto("unknown"))
+}
+
+func Test_Registry_RegisterLoad(t *testing.T) {
+	mgr := newManager()
+	_, err := mgr.Registry.Load()
+	assert.Contains(t, err.Error(), "unset")
+
+	mgr.RedisPoolMap = pool.NewRedisPoolMap()
+	p, _, _ := mgr.RedisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	p.Cmd("del", mgr.RegistryName())
+
+	msg := &Msg{Topic: "user", BID: "10.0.0.1", RID: "100|a"}
+	e, _ := NewRegEntry(msg, 10)
+	assert.Nil(t, mgr.Registry.Register(e))
+	assert.Nil(t, mgr.Registry.Register(e))
+	assert.Equal(t, 1, len(mgr.Registry.List()))
+
+	// 过期或异常数据，加载时清理
+	expired := &RegEntry{Service: "old", Host: "10.0.0.1", Pid: "1", ExpireAt: 1}
+	mgr.Registry.Register(expired)
+	p.Cmd("hset", mgr.RegistryName(), "wrong", "x")
+
+	n, err := mgr.Registry.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, mgr.Registry.IsRegistered(msg))
+	v, _ := p.Cmd("hlen", mgr.RegistryName()).Int()
+	assert.Equal(t, 1, v)
+
+	// 加载期间心跳已更新，不删除
+	assert.Nil(t, mgr.Registry.Register(e))
+	v, _ = util.LuaEval(p, luaHdelIfSame, 1, mgr.RegistryName(), e.Key(), "stale").Int()
+	assert.Equal(t, 0, v)
+	v, _ = p.Cmd("hexists", mgr.RegistryName(), e.Key()).Int()
+	assert.Equal(t, 1, v)
+
+	assert.Nil(t, mgr.Registry.Unregister(e))
+	assert.False(t, mgr.Registry.IsRegistered(msg))
+	n, _ = mgr.Registry.Load()
+	assert.Equal(t, 0, n)
+}
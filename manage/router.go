@@ -0,0 +1,156 @@
+package manage
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Router 服务路由表，ServiceName（topic/channel 或 topic） => broker 节点列表
+type Router struct {
+	// V 当前路由配置版本
+	V string
+
+	lock           *sync.RWMutex
+	routeMap       map[string]*route
+	balancerGenMap map[string]BalancerGenFn
+}
+
+type route struct {
+	nodes    []*RouteNode
+	balancer IBalancer
+}
+
+// NewRouter 构建空的路由表（所有服务均路由至本机）
+func NewRouter() *Router {
+	r := &Router{
+		lock:           new(sync.RWMutex),
+		routeMap:       make(map[string]*route),
+		balancerGenMap: make(map[string]BalancerGenFn),
+	}
+
+	r.AddBalancerGenFn(BalanceRoundRobin, NewRoundRobinBalancer)
+	r.AddBalancerGenFn(BalanceWeight, NewWeightBalancer)
+	r.AddBalancerGenFn(BalanceRandom, NewRandomBalancer)
+	r.AddBalancerGenFn(BalanceLeast, NewLeastBalancer)
+	return r
+}
+
+// AddBalancerGenFn 添加（或覆盖）指定名称的负载均衡策略，需在 Update 前添加
+func (r *Router) AddBalancerGenFn(name string, fn BalancerGenFn) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.balancerGenMap[name] = fn
+}
+
+// Update 使用配置更新路由表，"v" 为版本字段，返回配置有误（被忽略）的服务名
+func (r *Router) Update(v string, confMap map[string]string) []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	routeMap := make(map[string]*route)
+	wrongs := []string{}
+
+	for name, dsl := range confMap {
+		if name == "v" {
+			continue
+		}
+
+		if rt := r.dslToRoute(name, dsl); rt != nil {
+			routeMap[name] = rt
+		} else {
+			wrongs = append(wrongs, name)
+		}
+	}
+
+	r.V = v
+	r.routeMap = routeMap
+	return wrongs
+}
+
+// Route 返回 msg 的目标节点，优先匹配 ServiceName，其次 Topic，未配置则返回 nil（本机）
+func (r *Router) Route(msg *Msg) *RouteNode {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, name := range []string{msg.ServiceName(), msg.Topic} {
+		if rt := r.routeMap[name]; rt != nil {
+			return rt.balancer.Pick(rt.nodes)
+		}
+	}
+
+	return nil
+}
+
+// Done 投递至 node 的请求已完成（若路由已更新则忽略）
+func (r *Router) Done(node *RouteNode) {
+	if node == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	rt := r.routeMap[node.Service]
+	if rt == nil {
+		return
+	}
+
+	for _, item := range rt.nodes {
+		if item == node {
+			rt.balancer.Done(node)
+			return
+		}
+	}
+}
+
+// dslToRoute dsl 规则
+// 10.0.0.1,10.0.0.2 => 轮流投递
+// 10.0.0.1*3,10.0.0.2|weight => 按权重 3:1 投递，可选策略 rr, weight, random, least
+func (r *Router) dslToRoute(name, dsl string) *route {
+	ss := strings.SplitN(dsl, "|", 2)
+
+	strategy := BalanceRoundRobin
+	if len(ss) == 2 {
+		strategy = strings.TrimSpace(ss[1])
+	}
+
+	gen := r.balancerGenMap[strategy]
+	if gen == nil {
+		return nil
+	}
+
+	nodes := []*RouteNode{}
+	for _, item := range strings.Split(ss[0], ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		node := &RouteNode{
+			Service: name,
+			IP:      item,
+			Weight:  1,
+		}
+
+		if arr := strings.SplitN(item, "*", 2); len(arr) == 2 {
+			w, err := strconv.Atoi(arr[1])
+			if err != nil || w < 1 {
+				return nil
+			}
+			node.IP = arr[0]
+			node.Weight = w
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	return &route{
+		nodes:    nodes,
+		balancer: gen(),
+	}
+}
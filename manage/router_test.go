@@ -0,0 +1,95 @@
+package manage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBalancer struct {
+	doneTimes int
+}
+
+func (b *testBalancer) Pick(nodes []*RouteNode) *RouteNode {
+	return nodes[len(nodes)-1]
+}
+
+func (b *testBalancer) Done(node *RouteNode) {
+	b.doneTimes++
+}
+
+func Test_Router_Route(t *testing.T) {
+	r := NewRouter()
+	msg := &Msg{Topic: "user", Channel: "login"}
+
+	// 未配置，路由至本机
+	assert.Nil(t, r.Route(msg))
+
+	wrongs := r.Update("1", map[string]string{
+		"v":          "1",
+		"user":       "10.0.0.1",
+		"user/login": " 10.0.0.2, ,10.0.0.3",
+		"empty":      "",
+	})
+	assert.Equal(t, "1", r.V)
+	assert.Equal(t, []string{"empty"}, wrongs)
+
+	// 优先匹配 ServiceName，多个 ip 轮流投递
+	assert.Equal(t, "10.0.0.2", r.Route(msg).IP)
+	assert.Equal(t, "10.0.0.3", r.Route(msg).IP)
+	assert.Equal(t, "10.0.0.2", r.Route(msg).IP)
+	assert.Equal(t, "user/login", r.Route(msg).Service)
+
+	// 其次匹配 Topic
+	msg.Channel = "logout"
+	assert.Equal(t, "10.0.0.1", r.Route(msg).IP)
+
+	msg.Topic = "empty"
+	msg.Channel = ""
+	assert.Nil(t, r.Route(msg))
+
+	// 清空
+	r.Update("", map[string]string{})
+	msg.Topic = "user"
+	assert.Nil(t, r.Route(msg))
+}
+
+func Test_Router_dslToRoute(t *testing.T) {
+	r := NewRouter()
+
+	for _, dsl := range []string{"", ",", "10.0.0.1|unknown", "10.0.0.1*x", "10.0.0.1*0"} {
+		assert.Nil(t, r.dslToRoute("test", dsl), dsl)
+	}
+
+	rt := r.dslToRoute("test", "10.0.0.1*3,10.0.0.2:6380|weight")
+	assert.NotNil(t, rt)
+	assert.Equal(t, 2, len(rt.nodes))
+	assert.Equal(t, "10.0.0.1", rt.nodes[0].IP)
+	assert.Equal(t, 3, rt.nodes[0].Weight)
+	assert.Equal(t, "10.0.0.2:6380", rt.nodes[1].IP)
+	assert.Equal(t, 1, rt.nodes[1].Weight)
+	assert.IsType(t, &weightBalancer{}, rt.balancer)
+
+	rt = r.dslToRoute("test", "10.0.0.1")
+	assert.IsType(t, &roundRobinBalancer{}, rt.balancer)
+}
+
+func Test_Router_AddBalancerGenFn(t *testing.T) {
+	r := NewRouter()
+	b := &testBalancer{}
+	r.AddBalancerGenFn("last", func() IBalancer { return b })
+
+	r.Update("1", map[string]string{"test": "10.0.0.1,10.0.0.2|last"})
+	msg := &Msg{Topic: "test"}
+	node := r.Route(msg)
+	assert.Equal(t, "10.0.0.2", node.IP)
+
+	r.Done(node)
+	r.Done(nil)
+	assert.Equal(t, 1, b.doneTimes)
+
+	// 路由已更新，忽略旧节点
+	r.Update("2", map[string]string{"test": "10.0.0.1,10.0.0.2|last"})
+	r.Done(node)
+	assert.Equal(t, 1, b.doneTimes)
+}
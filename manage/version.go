@@ -0,0 +1,56 @@
+package manage
+
+import (
+	"strconv"
+	"sync"
+)
+
+// VersionTable 配置的各 inbox（ 服务 Topic 或调用者 pid ）偏好的协议版本
+type VersionTable struct {
+	// V 当前配置版本
+	V string
+
+	lock    *sync.RWMutex
+	confMap map[string]uint
+}
+
+// NewVersionTable 构建空的协议版本表
+func NewVersionTable() *VersionTable {
+	return &VersionTable{
+		lock:    new(sync.RWMutex),
+		confMap: make(map[string]uint),
+	}
+}
+
+// Update 使用配置更新（ inbox => version ），"v" 为版本字段，返回配置有误（被忽略）的名称
+func (t *VersionTable) Update(v string, confMap map[string]string) []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	vMap := make(map[string]uint)
+	wrongs := []string{}
+
+	for name, s := range confMap {
+		if name == "v" {
+			continue
+		}
+
+		if pv, err := strconv.ParseUint(s, 10, 8); err == nil && pv > 0 {
+			vMap[name] = uint(pv)
+		} else {
+			wrongs = append(wrongs, name)
+		}
+	}
+
+	t.V = v
+	t.confMap = vMap
+	return wrongs
+}
+
+// Get 返回 inbox 偏好的协议版本，未知返回 0
+func (t *VersionTable) Get(box string) uint {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.confMap[box]
+}
@@ -0,0 +1,22 @@
+package manage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_VersionTable_Update(t *testing.T) {
+	vt := NewVersionTable()
+	wrongs := vt.Update("1", map[string]string{"v": "1", "svc": "2", "x": "a"})
+	assert.Equal(t, []string{"x"}, wrongs)
+	assert.Equal(t, []string{"0"}, vt.Update("1", map[string]string{"0": "0"}))
+	assert.Equal(t, []string{"big"}, vt.Update("1", map[string]string{"big": "256"}))
+	vt.Update("1", map[string]string{"svc": "2"})
+	assert.Equal(t, "1", vt.V)
+	assert.Equal(t, uint(2), vt.Get("svc"))
+	assert.Equal(t, uint(0), vt.Get("x"))
+
+	vt.Update("", map[string]string{})
+	assert.Equal(t, uint(0), vt.Get("svc"))
+}
@@ -2,12 +2,18 @@ package pool
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
 	"strings"
 	"time"
 
 	"github.com/kr/beanstalk"
 )
 
+// buryJobTimeout BuryJob 独立连接的读写超时
+const buryJobTimeout = 5 * time.Second
+
 // BeanClient beanstalkd client
 type BeanClient struct {
 	conn         *beanstalk.Conn
@@ -23,13 +29,17 @@ func NewBeanClient(addr string) *BeanClient {
 	}
 }
 
-// Close 关闭连接
+// Close 关闭连接（ 出错不可用的连接同样关闭 ）
 func (c *BeanClient) Close() error {
-	if c.IsAble() {
+	if c.conn == nil {
+		return nil
+	}
+	if c.LastCritical == nil {
 		c.LastCritical = errors.New("connection has closed")
-		return c.conn.Close()
 	}
-	return nil
+	conn := c.conn
+	c.conn = nil
+	return conn.Close()
 }
 
 // Stats 返回状态
@@ -55,6 +65,156 @@ func (c *BeanClient) Put(tubeName string, body []byte, pri uint32, delay, ttr ti
 	return id, err
 }
 
+// Reserve 从指定管道获取任务，超时未获取到则返回 beanstalk.ErrTimeout
+func (c *BeanClient) Reserve(tubeName string, timeout time.Duration) (uint64, []byte, error) {
+	if !c.IsAble() {
+		return 0, nil, errors.New("client disabled")
+	}
+	id, body, err := beanstalk.NewTubeSet(c.conn, tubeName).Reserve(timeout)
+	c.errCheck(err)
+	return id, body, err
+}
+
+// Release 释放本连接 reserve 的任务，延迟 delay 后重新 ready
+func (c *BeanClient) Release(id uint64, pri uint32, delay time.Duration) error {
+	if !c.IsAble() {
+		return errors.New("client disabled")
+	}
+	err := c.conn.Release(id, pri, delay)
+	c.errCheck(err)
+	return err
+}
+
+// Touch 延长本连接 reserve 的任务的 TTR
+func (c *BeanClient) Touch(id uint64) error {
+	if !c.IsAble() {
+		return errors.New("client disabled")
+	}
+	err := c.conn.Touch(id)
+	c.errCheck(err)
+	return err
+}
+
+// Delete 删除任务
+func (c *BeanClient) Delete(id uint64) error {
+	if !c.IsAble() {
+		return errors.New("client disabled")
+	}
+	err := c.conn.Delete(id)
+	c.errCheck(err)
+	return err
+}
+
+// Kick 将 buried 或 delayed 的任务转为 ready
+func (c *BeanClient) Kick(id uint64) error {
+	if !c.IsAble() {
+		return errors.New("client disabled")
+	}
+	err := c.conn.KickJob(id)
+	c.errCheck(err)
+	return err
+}
+
+// Peek 获取任务内容
+func (c *BeanClient) Peek(id uint64) ([]byte, error) {
+	if !c.IsAble() {
+		return nil, errors.New("client disabled")
+	}
+	body, err := c.conn.Peek(id)
+	c.errCheck(err)
+	return body, err
+}
+
+// Bury 搁置任务（ beanstalkd 仅允许搁置本连接 reserve 的任务 ）
+func (c *BeanClient) Bury(id uint64, pri uint32) error {
+	if !c.IsAble() {
+		return errors.New("client disabled")
+	}
+	err := c.conn.Bury(id, pri)
+	c.errCheck(err)
+	return err
+}
+
+// BuryJob 搁置任意任务：beanstalkd 仅允许搁置本连接 reserve 的任务，
+// 故使用独立连接先 reserve-job（ beanstalkd >= 1.12 ）再 bury，任务被其它连接 reserve 时返回 NOT_FOUND
+func BuryJob(addr string, id uint64, pri uint32) error {
+	nc, err := net.DialTimeout("tcp", addr, buryJobTimeout)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+	nc.SetDeadline(time.Now().Add(buryJobTimeout))
+
+	c := textproto.NewConn(nc)
+
+	line, err := rawCmd(c, "reserve-job", "reserve-job %d", id)
+	if err != nil {
+		return err
+	}
+
+	var idReserved uint64
+	var size int
+	if _, err = fmt.Sscanf(line, "RESERVED %d %d", &idReserved, &size); err != nil {
+		return beanstalk.ConnError{Op: "reserve-job", Err: errors.New(line)}
+	}
+	// 丢弃任务内容及结尾的 \r\n
+	if _, err = c.R.Discard(size + 2); err != nil {
+		return err
+	}
+
+	line, err = rawCmd(c, "bury", "bury %d %d", id, pri)
+	if err != nil {
+		return err
+	}
+	if line != "BURIED" {
+		return beanstalk.ConnError{Op: "bury", Err: errors.New(line)}
+	}
+	return nil
+}
+
+// rawCmd 发送命令并读取应答首行，NOT_FOUND 转为 beanstalk.ErrNotFound
+func rawCmd(c *textproto.Conn, op, format string, args ...interface{}) (string, error) {
+	if err := c.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+
+	line, err := c.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if line == "NOT_FOUND" {
+		return "", beanstalk.ConnError{Op: op, Err: beanstalk.ErrNotFound}
+	}
+	return line, nil
+}
+
+// StatsJob 返回任务状态
+func (c *BeanClient) StatsJob(id uint64) (map[string]string, error) {
+	if !c.IsAble() {
+		return nil, errors.New("client disabled")
+	}
+	info, err := c.conn.StatsJob(id)
+	c.errCheck(err)
+	return info, err
+}
+
+// IsNotFound 是否任务（ 或 tube ）不存在的错误
+func IsNotFound(err error) bool {
+	return connErr(err) == beanstalk.ErrNotFound
+}
+
+// IsTimeout 是否 Reserve 超时的错误
+func IsTimeout(err error) bool {
+	return connErr(err) == beanstalk.ErrTimeout
+}
+
+func connErr(err error) error {
+	if e, ok := err.(beanstalk.ConnError); ok {
+		return e.Err
+	}
+	return err
+}
+
 func (c *BeanClient) getTube(name string) *beanstalk.Tube {
 	return &beanstalk.Tube{
 		Conn: c.conn,
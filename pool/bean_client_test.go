@@ -1,6 +1,7 @@
 package pool
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -28,6 +29,13 @@ func Test_BeanClient_Close(t *testing.T) {
 	c := okBeanClient()
 	c.Close()
 	assert.NotNil(t, c.LastCritical)
+	assert.Nil(t, c.Close())
+
+	// 出错不可用的连接同样关闭
+	c = okBeanClient()
+	c.LastCritical = errors.New("EOF")
+	assert.Nil(t, c.Close())
+	assert.Nil(t, c.conn)
 }
 
 func Test_BeanClient_Stats(t *testing.T) {
@@ -49,3 +57,75 @@ func Test_BeanClient_Put(t *testing.T) {
 	_, errPut := c.Put("default", data, pri, delay, ttr)
 	assert.Nil(t, errPut)
 }
+
+func Test_BeanClient_JobCtl(t *testing.T) {
+	c := okBeanClient()
+	id, err := c.Put("default", []byte("hello"), 0, time.Minute, time.Minute)
+	assert.Nil(t, err)
+
+	body, err := c.Peek(id)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), body)
+
+	info, err := c.StatsJob(id)
+	assert.Nil(t, err)
+	assert.Equal(t, "delayed", info["state"])
+
+	assert.Nil(t, c.Kick(id))
+	info, _ = c.StatsJob(id)
+	assert.Equal(t, "ready", info["state"])
+
+	// 未 reserve 的任务无法搁置
+	assert.True(t, IsNotFound(c.Bury(id, 0)))
+
+	// 独立连接 reserve 后搁置
+	assert.Nil(t, BuryJob("127.0.0.1:11300", id, 3))
+	info, _ = c.StatsJob(id)
+	assert.Equal(t, "buried", info["state"])
+	assert.Equal(t, "3", info["pri"])
+
+	assert.Nil(t, c.Delete(id))
+	_, err = c.Peek(id)
+	assert.True(t, IsNotFound(err))
+	assert.True(t, IsNotFound(c.Delete(id)))
+	assert.False(t, IsNotFound(nil))
+
+	c.Close()
+	_, err = c.Peek(id)
+	assert.Contains(t, err.Error(), "disabled")
+}
+
+func Test_BeanClient_Reserve(t *testing.T) {
+	c := okBeanClient()
+	tube := "test-reserve"
+	_, _, err := c.Reserve(tube, 0)
+	assert.True(t, IsTimeout(err))
+
+	id, _ := c.Put(tube, []byte("hello"), 0, 0, time.Minute)
+	idReserved, body, err := c.Reserve(tube, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, id, idReserved)
+	assert.Equal(t, []byte("hello"), body)
+
+	assert.Nil(t, c.Touch(id))
+	assert.Nil(t, c.Release(id, 0, time.Minute))
+	info, _ := c.StatsJob(id)
+	assert.Equal(t, "delayed", info["state"])
+	assert.Equal(t, "1", info["releases"])
+
+	// 非本连接 reserve 的任务
+	assert.True(t, IsNotFound(c.Touch(id)))
+
+	// 已被其它连接 reserve 的任务无法搁置
+	assert.Nil(t, c.Kick(id))
+	c.Reserve(tube, time.Second)
+	assert.True(t, IsNotFound(BuryJob("127.0.0.1:11300", id, 0)))
+	assert.Nil(t, c.Release(id, 0, time.Minute))
+
+	assert.Nil(t, c.Kick(id))
+	c.Reserve(tube, time.Second)
+	assert.Nil(t, c.Bury(id, 0))
+	info, _ = c.StatsJob(id)
+	assert.Equal(t, "buried", info["state"])
+	assert.Nil(t, c.Delete(id))
+}
@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/chashu-code/micro-broker/manage"
+)
+
+// JSONProtocol JSON 协议，字段名与 V1Protocol 的 tag 一致（ 另有 V2 的 hdr ），
+// 便于无法使用 msgpack 的客户端接入，及在 redis-cli 中直接查看；st、dl 同 V1 为 unix 秒。
+//
+// Data 的处理：
+//   - 解析时，整数（ 可由 int64 表示 ）转为 int64，其它数字转为 float64（ 超出范围的
+//     保留为字符串 ），对象转为 map[string]interface{}，数组转为 []interface{}，
+//     以便再以 msgpack 协议投递
+//   - 序列化时，[]byte 按 encoding/json 的规则转为 base64 字符串，解析后为 string
+type JSONProtocol struct {
+	Action    string            `json:"act"`
+	BID       string            `json:"bid"`
+	RID       string            `json:"rid"`
+	TID       string            `json:"tid"`
+	Topic     string            `json:"topic"`
+	Channel   string            `json:"chan"`
+	Nav       string            `json:"nav"`
+	SendTime  int64             `json:"st"`
+	DeadLine  int64             `json:"dl"`
+	DeliverAt int64             `json:"da,omitempty"`
+	Headers   map[string]string `json:"hdr,omitempty"`
+
+	Data interface{} `json:"data"`
+	Code string      `json:"code"`
+}
+
+// NewJSONProtocol 构造 JSON 协议
+func NewJSONProtocol() manage.IProtocol {
+	return &JSONProtocol{}
+}
+
+// BytesToMsg bytes => msg
+func (p *JSONProtocol) BytesToMsg(bts []byte) (*manage.Msg, error) {
+	*p = JSONProtocol{}
+
+	dec := json.NewDecoder(bytes.NewReader(bts))
+	dec.UseNumber()
+	if err := dec.Decode(p); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("json protocol with trailing data")
+	}
+
+	msg := &manage.Msg{
+		Action:    p.Action,
+		BID:       p.BID,
+		RID:       p.RID,
+		TID:       p.TID,
+		Topic:     p.Topic,
+		Channel:   p.Channel,
+		Nav:       p.Nav,
+		SendTime:  secsToMs(p.SendTime),
+		DeadLine:  deadLineToMs(p.DeadLine),
+		DeliverAt: p.DeliverAt,
+		Headers:   p.Headers,
+		Data:      jsonData(p.Data),
+		Code:      p.Code,
+		V:         VersionJSON,
+	}
+
+	return msg, nil
+}
+
+// MsgToBytes msg => bytes
+func (p *JSONProtocol) MsgToBytes(msg *manage.Msg) ([]byte, error) {
+	p.Action = msg.Action
+	p.BID = msg.BID
+	p.RID = msg.RID
+	p.TID = msg.TID
+	p.Topic = msg.Topic
+	p.Channel = msg.Channel
+	p.Nav = msg.Nav
+	p.SendTime = msToSecs(msg.SendTime)
+	p.DeadLine = msToSecs(msg.DeadLine)
+	p.DeliverAt = msg.DeliverAt
+	p.Headers = msg.Headers
+	p.Data = msg.Data
+	p.Code = msg.Code
+
+	return json.Marshal(p)
+}
+
+// jsonData 将 json.Number 转为 int64 或 float64（ 超出范围则保留为字符串 ）
+func jsonData(v interface{}) interface{} {
+	switch d := v.(type) {
+	case json.Number:
+		if i, err := d.Int64(); err == nil {
+			return i
+		}
+		if f, err := d.Float64(); err == nil {
+			return f
+		}
+		return d.String()
+	case map[string]interface{}:
+		for k, item := range d {
+			d[k] = jsonData(item)
+		}
+	case []interface{}:
+		for i, item := range d {
+			d[i] = jsonData(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,169 @@
+package protocol
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/chashu-code/micro-broker/manage"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_JSONProtocol(t *testing.T) {
+	msg := &manage.Msg{
+		Action:    manage.ActReq,
+		BID:       "b",
+		RID:       "1|a",
+		TID:       "t",
+		Topic:     "test",
+		Channel:   "c",
+		Nav:       "n",
+		SendTime:  100000,
+		DeadLine:  110999,
+		DeliverAt: 105,
+		Headers:   map[string]string{manage.HeaderContentType: "json"},
+		Data: map[string]interface{}{
+			"id":    int64(9007199254740993),
+			"price": 1.5,
+			"tags":  []interface{}{"a", int64(1)},
+		},
+		Code: "0",
+		V:    VersionJSON,
+	}
+
+	bts, err := NewJSONProtocol().MsgToBytes(msg)
+	assert.Nil(t, err)
+	assert.Contains(t, string(bts), `"act":"req"`)
+	assert.Contains(t, string(bts), `"chan":"c"`)
+	assert.Contains(t, string(bts), `"dl":110,`)
+
+	msgNew, err := NewJSONProtocol().BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, msg, msgNew)
+
+	// 可转为 V1 投递
+	_, err = NewV1Protocol().MsgToBytes(msgNew)
+	assert.Nil(t, err)
+
+	// []byte 转为 base64 字符串
+	msg.Data = []byte("hi")
+	bts, _ = NewJSONProtocol().MsgToBytes(msg)
+	msgNew, _ = NewJSONProtocol().BytesToMsg(bts)
+	assert.Equal(t, "aGk=", msgNew.Data)
+
+	// 超出范围的数字保留为字符串
+	msgNew, err = NewJSONProtocol().BytesToMsg([]byte(`{"data":1e400}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "1e400", msgNew.Data)
+
+	_, err = NewJSONProtocol().BytesToMsg([]byte(`{"act":1}`))
+	assert.NotNil(t, err)
+	_, err = NewJSONProtocol().BytesToMsg([]byte(`{} {}`))
+	assert.Contains(t, err.Error(), "trailing")
+}
+
+// randJSONData 随机生成 JSON 可表示的 Data
+func randJSONData(r *rand.Rand, depth int) interface{} {
+	n := 6
+	if depth > 2 {
+		n = 4
+	}
+
+	switch r.Intn(n) {
+	case 0:
+		return nil
+	case 1:
+		return r.Int63() - r.Int63()
+	case 2:
+		return r.NormFloat64()
+	case 3:
+		return randString(r)
+	case 4:
+		mp := map[string]interface{}{}
+		for i := r.Intn(4); i > 0; i-- {
+			mp[randString(r)] = randJSONData(r, depth+1)
+		}
+		return mp
+	default:
+		lst := []interface{}{}
+		for i := r.Intn(4); i > 0; i-- {
+			lst = append(lst, randJSONData(r, depth+1))
+		}
+		return lst
+	}
+}
+
+func randString(r *rand.Rand) string {
+	v, _ := quick.Value(reflect.TypeOf(""), r)
+	return v.String()
+}
+
+type quickMsg struct {
+	msg *manage.Msg
+}
+
+func (quickMsg) Generate(r *rand.Rand, size int) reflect.Value {
+	msg := &manage.Msg{
+		Action:    randString(r),
+		BID:       randString(r),
+		RID:       randString(r),
+		TID:       randString(r),
+		Topic:     randString(r),
+		Channel:   randString(r),
+		Nav:       randString(r),
+		SendTime:  r.Int63() / 1000 * 1000,
+		DeadLine:  r.Int63()/1000*1000 + 999,
+		DeliverAt: r.Int63(),
+		Data:      randJSONData(r, 0),
+		Code:      randString(r),
+		V:         VersionJSON,
+	}
+	if r.Intn(2) == 0 {
+		msg.Headers = map[string]string{randString(r): randString(r)}
+	}
+	return reflect.ValueOf(quickMsg{msg})
+}
+
+func Test_JSONProtocol_QuickRoundTrip(t *testing.T) {
+	fn := func(q quickMsg) bool {
+		bts, err := NewJSONProtocol().MsgToBytes(q.msg)
+		if err != nil {
+			return false
+		}
+		msgNew, err := NewJSONProtocol().BytesToMsg(bts)
+		return err == nil && reflect.DeepEqual(q.msg, msgNew)
+	}
+	assert.Nil(t, quick.Check(fn, &quick.Config{MaxCount: 500}))
+}
+
+// FuzzJSONProtocol 任意内容解析不应 panic，解析成功则可再次序列化
+func FuzzJSONProtocol(f *testing.F) {
+	seed, _ := NewJSONProtocol().MsgToBytes(&manage.Msg{
+		Action:  manage.ActReq,
+		Topic:   "test",
+		Headers: map[string]string{manage.HeaderContentType: "json"},
+		Data:    map[string]interface{}{"a": []interface{}{int64(1), "b", 1.5, nil}},
+	})
+	f.Add(seed)
+	f.Add([]byte(""))
+	f.Add([]byte("{}"))
+	f.Add([]byte(`{"act":"req","data":1e400}`))
+	f.Add([]byte(`{"act":"req","data":9007199254740993}{}`))
+	f.Add([]byte(strings.Repeat("[", 100)))
+
+	f.Fuzz(func(t *testing.T, bts []byte) {
+		msg, err := NewJSONProtocol().BytesToMsg(bts)
+		if (msg == nil) == (err == nil) {
+			t.Fatalf("msg %v with err %v", msg, err)
+		}
+		if err != nil {
+			return
+		}
+
+		if _, err = NewJSONProtocol().MsgToBytes(msg); err != nil {
+			t.Fatalf("input %q, msg to bytes fail: %v", bts, err)
+		}
+	})
+}
@@ -38,8 +38,8 @@ func (p *V1Protocol) BytesToMsg(bts []byte) (*manage.Msg, error) {
 		Topic:    p.Topic,
 		Channel:  p.Channel,
 		Nav:      p.Nav,
-		SendTime: int64(p.SendTime),
-		DeadLine: int64(p.DeadLine),
+		SendTime: secsToMs(int64(p.SendTime)),
+		DeadLine: deadLineToMs(int64(p.DeadLine)),
 		Data:     p.Data,
 		Code:     p.Code,
 		V:        uint(1),
@@ -58,9 +58,27 @@ func (p *V1Protocol) MsgToBytes(msg *manage.Msg) ([]byte, error) {
 	p.Nav = msg.Nav
 	p.Code = msg.Code
 	p.Data = msg.Data
-	p.SendTime = uint(msg.SendTime)
-	p.DeadLine = uint(msg.DeadLine)
+	p.SendTime = uint(msToSecs(msg.SendTime))
+	p.DeadLine = uint(msToSecs(msg.DeadLine))
 
 	return p.MarshalMsg(nil)
 
 }
+
+// secsToMs 协议中的 unix 秒 => Msg 的 unix 毫秒
+func secsToMs(secs int64) int64 {
+	return secs * 1000
+}
+
+// deadLineToMs 协议中的 unix 秒截止时间 => Msg 的 unix 毫秒，取该秒的最后 1 毫秒（ 避免提前过期 ），0 仍为 0
+func deadLineToMs(secs int64) int64 {
+	if secs == 0 {
+		return 0
+	}
+	return secs*1000 + 999
+}
+
+// msToSecs Msg 的 unix 毫秒 => 协议中的 unix 秒（ 舍去不足 1 秒的部分 ）
+func msToSecs(ms int64) int64 {
+	return ms / 1000
+}
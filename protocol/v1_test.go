@@ -0,0 +1,52 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chashu-code/micro-broker/manage"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_V1Protocol_DeliverAt(t *testing.T) {
+	msg := &manage.Msg{
+		Action:    manage.ActReq,
+		RID:       "1|a",
+		Topic:     "test",
+		DeadLine:  100500,
+		DeliverAt: 90,
+		V:         1,
+	}
+
+	bts, err := NewV1Protocol().MsgToBytes(msg)
+	assert.Nil(t, err)
+
+	// V1 协议格式不变，不携带延迟投递时间，立即投递
+	msgNew, err := NewV1Protocol().BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), msgNew.DeliverAt)
+	assert.True(t, msgNew.IsDue())
+	// 以秒传输，截止时间取该秒的最后 1 毫秒
+	assert.Equal(t, int64(100999), msgNew.DeadLine)
+
+	// V2 起携带
+	bts, _ = NewV2Protocol().MsgToBytes(msg)
+	msgNew, _ = NewV2Protocol().BytesToMsg(bts)
+	assert.Equal(t, int64(90), msgNew.DeliverAt)
+}
+
+func Test_V1Protocol_IsDead(t *testing.T) {
+	now := time.Now().Unix()
+	p := &V1Protocol{Action: manage.ActReq, RID: "1|a", Topic: "test", DeadLine: uint(now)}
+	bts, _ := p.MarshalMsg(nil)
+
+	// 截止于当前秒，尚未过期
+	msg, err := NewV1Protocol().BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.False(t, msg.IsDead())
+
+	p.DeadLine = uint(now - 1)
+	bts, _ = p.MarshalMsg(nil)
+	msg, _ = NewV1Protocol().BytesToMsg(bts)
+	assert.True(t, msg.IsDead())
+}
@@ -0,0 +1,73 @@
+package protocol
+
+import "github.com/chashu-code/micro-broker/manage"
+
+//go:generate msgp
+
+// V2Protocol 在 V1 的基础上增加可扩展的 Headers（ 如 auth、baggage、content-type、reply-to ）
+type V2Protocol struct {
+	Action    string `msg:"act"`
+	BID       string `msg:"bid"`
+	RID       string `msg:"rid"`
+	TID       string `msg:"tid"`
+	Topic     string `msg:"topic"`
+	Channel   string `msg:"chan"`
+	Nav       string `msg:"nav"`
+	SendTime  uint   `msg:"st"`
+	DeadLine  uint   `msg:"dl"`
+	DeliverAt uint   `msg:"da"`
+
+	Headers map[string]string `msg:"hdr"`
+
+	Data interface{} `msg:"data"`
+	Code string      `msg:"code"`
+}
+
+func NewV2Protocol() manage.IProtocol {
+	return &V2Protocol{}
+}
+
+func (p *V2Protocol) BytesToMsg(bts []byte) (*manage.Msg, error) {
+	_, err := p.UnmarshalMsg(bts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &manage.Msg{
+		Action:    p.Action,
+		BID:       p.BID,
+		RID:       p.RID,
+		TID:       p.TID,
+		Topic:     p.Topic,
+		Channel:   p.Channel,
+		Nav:       p.Nav,
+		SendTime:  secsToMs(int64(p.SendTime)),
+		DeadLine:  deadLineToMs(int64(p.DeadLine)),
+		DeliverAt: int64(p.DeliverAt),
+		Headers:   p.Headers,
+		Data:      p.Data,
+		Code:      p.Code,
+		V:         VersionHeaders,
+	}
+
+	return msg, nil
+}
+
+func (p *V2Protocol) MsgToBytes(msg *manage.Msg) ([]byte, error) {
+	p.Action = msg.Action
+	p.BID = msg.BID
+	p.RID = msg.RID
+	p.TID = msg.TID
+	p.Topic = msg.Topic
+	p.Channel = msg.Channel
+	p.Nav = msg.Nav
+	p.Code = msg.Code
+	p.Data = msg.Data
+	p.SendTime = uint(msToSecs(msg.SendTime))
+	p.DeadLine = uint(msToSecs(msg.DeadLine))
+	p.DeliverAt = uint(msg.DeliverAt)
+	p.Headers = msg.Headers
+
+	return p.MarshalMsg(nil)
+}
@@ -0,0 +1,41 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/chashu-code/micro-broker/manage"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_V2Protocol(t *testing.T) {
+	msg := &manage.Msg{
+		Action:  manage.ActReq,
+		RID:     "1|a",
+		Topic:   "test",
+		Headers: map[string]string{manage.HeaderAuth: "token", "baggage-uid": "7"},
+		Data:    "hello",
+		V:       VersionHeaders,
+	}
+
+	bts, err := NewV2Protocol().MsgToBytes(msg)
+	assert.Nil(t, err)
+
+	msgNew, err := NewV2Protocol().BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, msg.Headers, msgNew.Headers)
+	assert.Equal(t, "hello", msgNew.Data)
+	assert.Equal(t, uint(VersionHeaders), msgNew.V)
+
+	// V1 可解析 V2 内容（ 忽略 Headers ）
+	msgV1, err := NewV1Protocol().BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, "test", msgV1.Topic)
+	assert.Nil(t, msgV1.Headers)
+
+	// V2 可解析 V1 内容
+	bts, _ = NewV1Protocol().MsgToBytes(msg)
+	msgNew, err = NewV2Protocol().BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, "test", msgNew.Topic)
+	assert.Empty(t, msgNew.Headers)
+}
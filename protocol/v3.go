@@ -0,0 +1,74 @@
+package protocol
+
+import "github.com/chashu-code/micro-broker/manage"
+
+//go:generate msgp
+
+// V3Protocol 在 V2 的基础上以 unix 毫秒传输 SendTime、DeadLine（ 字段名改为 stm、dlm，
+// 避免被旧版本按秒解析 ），以支持小于 1 秒的超时；DeliverAt 仍为 unix 秒
+type V3Protocol struct {
+	Action    string `msg:"act"`
+	BID       string `msg:"bid"`
+	RID       string `msg:"rid"`
+	TID       string `msg:"tid"`
+	Topic     string `msg:"topic"`
+	Channel   string `msg:"chan"`
+	Nav       string `msg:"nav"`
+	SendTime  int64  `msg:"stm"`
+	DeadLine  int64  `msg:"dlm"`
+	DeliverAt uint   `msg:"da"`
+
+	Headers map[string]string `msg:"hdr"`
+
+	Data interface{} `msg:"data"`
+	Code string      `msg:"code"`
+}
+
+func NewV3Protocol() manage.IProtocol {
+	return &V3Protocol{}
+}
+
+func (p *V3Protocol) BytesToMsg(bts []byte) (*manage.Msg, error) {
+	_, err := p.UnmarshalMsg(bts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &manage.Msg{
+		Action:    p.Action,
+		BID:       p.BID,
+		RID:       p.RID,
+		TID:       p.TID,
+		Topic:     p.Topic,
+		Channel:   p.Channel,
+		Nav:       p.Nav,
+		SendTime:  p.SendTime,
+		DeadLine:  p.DeadLine,
+		DeliverAt: int64(p.DeliverAt),
+		Headers:   p.Headers,
+		Data:      p.Data,
+		Code:      p.Code,
+		V:         VersionMsDeadline,
+	}
+
+	return msg, nil
+}
+
+func (p *V3Protocol) MsgToBytes(msg *manage.Msg) ([]byte, error) {
+	p.Action = msg.Action
+	p.BID = msg.BID
+	p.RID = msg.RID
+	p.TID = msg.TID
+	p.Topic = msg.Topic
+	p.Channel = msg.Channel
+	p.Nav = msg.Nav
+	p.Code = msg.Code
+	p.Data = msg.Data
+	p.SendTime = msg.SendTime
+	p.DeadLine = msg.DeadLine
+	p.DeliverAt = uint(msg.DeliverAt)
+	p.Headers = msg.Headers
+
+	return p.MarshalMsg(nil)
+}
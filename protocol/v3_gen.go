@@ -0,0 +1,433 @@
+package protocol
+
+// NOTE: THIS FILE WAS PRODUCED BY THE
+// MSGP CODE GENERATION TOOL (github.com/tinylib/msgp)
+// DO NOT EDIT
+
+import "github.com/tinylib/msgp/msgp"
+
+// DecodeMsg implements msgp.Decodable
+func (z *V3Protocol) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var ztyy uint32
+	ztyy, err = dc.ReadMapHeader()
+	if err != nil {
+		return
+	}
+	for ztyy > 0 {
+		ztyy--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "act":
+			z.Action, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "bid":
+			z.BID, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "rid":
+			z.RID, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "tid":
+			z.TID, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "topic":
+			z.Topic, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "chan":
+			z.Channel, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "nav":
+			z.Nav, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "stm":
+			z.SendTime, err = dc.ReadInt64()
+			if err != nil {
+				return
+			}
+		case "dlm":
+			z.DeadLine, err = dc.ReadInt64()
+			if err != nil {
+				return
+			}
+		case "da":
+			z.DeliverAt, err = dc.ReadUint()
+			if err != nil {
+				return
+			}
+		case "hdr":
+			var zjfb uint32
+			zjfb, err = dc.ReadMapHeader()
+			if err != nil {
+				return
+			}
+			if z.Headers == nil {
+				z.Headers = make(map[string]string, zjfb)
+			} else if len(z.Headers) > 0 {
+				for key := range z.Headers {
+					delete(z.Headers, key)
+				}
+			}
+			for zjfb > 0 {
+				zjfb--
+				var zxpk string
+				var zeff string
+				zxpk, err = dc.ReadString()
+				if err != nil {
+					return
+				}
+				zeff, err = dc.ReadString()
+				if err != nil {
+					return
+				}
+				z.Headers[zxpk] = zeff
+			}
+		case "data":
+			z.Data, err = dc.ReadIntf()
+			if err != nil {
+				return
+			}
+		case "code":
+			z.Code, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *V3Protocol) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 13
+	// write "act"
+	err = en.Append(0x8d, 0xa3, 0x61, 0x63, 0x74)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Action)
+	if err != nil {
+		return
+	}
+	// write "bid"
+	err = en.Append(0xa3, 0x62, 0x69, 0x64)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.BID)
+	if err != nil {
+		return
+	}
+	// write "rid"
+	err = en.Append(0xa3, 0x72, 0x69, 0x64)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.RID)
+	if err != nil {
+		return
+	}
+	// write "tid"
+	err = en.Append(0xa3, 0x74, 0x69, 0x64)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.TID)
+	if err != nil {
+		return
+	}
+	// write "topic"
+	err = en.Append(0xa5, 0x74, 0x6f, 0x70, 0x69, 0x63)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Topic)
+	if err != nil {
+		return
+	}
+	// write "chan"
+	err = en.Append(0xa4, 0x63, 0x68, 0x61, 0x6e)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Channel)
+	if err != nil {
+		return
+	}
+	// write "nav"
+	err = en.Append(0xa3, 0x6e, 0x61, 0x76)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Nav)
+	if err != nil {
+		return
+	}
+	// write "stm"
+	err = en.Append(0xa3, 0x73, 0x74, 0x6d)
+	if err != nil {
+		return err
+	}
+	err = en.WriteInt64(z.SendTime)
+	if err != nil {
+		return
+	}
+	// write "dlm"
+	err = en.Append(0xa3, 0x64, 0x6c, 0x6d)
+	if err != nil {
+		return err
+	}
+	err = en.WriteInt64(z.DeadLine)
+	if err != nil {
+		return
+	}
+	// write "da"
+	err = en.Append(0xa2, 0x64, 0x61)
+	if err != nil {
+		return err
+	}
+	err = en.WriteUint(z.DeliverAt)
+	if err != nil {
+		return
+	}
+	// write "hdr"
+	err = en.Append(0xa3, 0x68, 0x64, 0x72)
+	if err != nil {
+		return err
+	}
+	err = en.WriteMapHeader(uint32(len(z.Headers)))
+	if err != nil {
+		return
+	}
+	for zrsw, zawn := range z.Headers {
+		err = en.WriteString(zrsw)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(zawn)
+		if err != nil {
+			return
+		}
+	}
+	// write "data"
+	err = en.Append(0xa4, 0x64, 0x61, 0x74, 0x61)
+	if err != nil {
+		return err
+	}
+	err = en.WriteIntf(z.Data)
+	if err != nil {
+		return
+	}
+	// write "code"
+	err = en.Append(0xa4, 0x63, 0x6f, 0x64, 0x65)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Code)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *V3Protocol) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 13
+	// string "act"
+	o = append(o, 0x8d, 0xa3, 0x61, 0x63, 0x74)
+	o = msgp.AppendString(o, z.Action)
+	// string "bid"
+	o = append(o, 0xa3, 0x62, 0x69, 0x64)
+	o = msgp.AppendString(o, z.BID)
+	// string "rid"
+	o = append(o, 0xa3, 0x72, 0x69, 0x64)
+	o = msgp.AppendString(o, z.RID)
+	// string "tid"
+	o = append(o, 0xa3, 0x74, 0x69, 0x64)
+	o = msgp.AppendString(o, z.TID)
+	// string "topic"
+	o = append(o, 0xa5, 0x74, 0x6f, 0x70, 0x69, 0x63)
+	o = msgp.AppendString(o, z.Topic)
+	// string "chan"
+	o = append(o, 0xa4, 0x63, 0x68, 0x61, 0x6e)
+	o = msgp.AppendString(o, z.Channel)
+	// string "nav"
+	o = append(o, 0xa3, 0x6e, 0x61, 0x76)
+	o = msgp.AppendString(o, z.Nav)
+	// string "stm"
+	o = append(o, 0xa3, 0x73, 0x74, 0x6d)
+	o = msgp.AppendInt64(o, z.SendTime)
+	// string "dlm"
+	o = append(o, 0xa3, 0x64, 0x6c, 0x6d)
+	o = msgp.AppendInt64(o, z.DeadLine)
+	// string "da"
+	o = append(o, 0xa2, 0x64, 0x61)
+	o = msgp.AppendUint(o, z.DeliverAt)
+	// string "hdr"
+	o = append(o, 0xa3, 0x68, 0x64, 0x72)
+	o = msgp.AppendMapHeader(o, uint32(len(z.Headers)))
+	for zrsw, zawn := range z.Headers {
+		o = msgp.AppendString(o, zrsw)
+		o = msgp.AppendString(o, zawn)
+	}
+	// string "data"
+	o = append(o, 0xa4, 0x64, 0x61, 0x74, 0x61)
+	o, err = msgp.AppendIntf(o, z.Data)
+	if err != nil {
+		return
+	}
+	// string "code"
+	o = append(o, 0xa4, 0x63, 0x6f, 0x64, 0x65)
+	o = msgp.AppendString(o, z.Code)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *V3Protocol) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zobc uint32
+	zobc, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		return
+	}
+	for zobc > 0 {
+		zobc--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "act":
+			z.Action, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "bid":
+			z.BID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "rid":
+			z.RID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "tid":
+			z.TID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "topic":
+			z.Topic, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "chan":
+			z.Channel, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "nav":
+			z.Nav, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "stm":
+			z.SendTime, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				return
+			}
+		case "dlm":
+			z.DeadLine, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				return
+			}
+		case "da":
+			z.DeliverAt, bts, err = msgp.ReadUintBytes(bts)
+			if err != nil {
+				return
+			}
+		case "hdr":
+			var zrfs uint32
+			zrfs, bts, err = msgp.ReadMapHeaderBytes(bts)
+			if err != nil {
+				return
+			}
+			if z.Headers == nil {
+				z.Headers = make(map[string]string, zrfs)
+			} else if len(z.Headers) > 0 {
+				for key := range z.Headers {
+					delete(z.Headers, key)
+				}
+			}
+			for zrfs > 0 {
+				var zkgt string
+				var zsnw string
+				zrfs--
+				zkgt, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					return
+				}
+				zsnw, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					return
+				}
+				z.Headers[zkgt] = zsnw
+			}
+		case "data":
+			z.Data, bts, err = msgp.ReadIntfBytes(bts)
+			if err != nil {
+				return
+			}
+		case "code":
+			z.Code, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *V3Protocol) Msgsize() (s int) {
+	s = 1 + 4 + msgp.StringPrefixSize + len(z.Action) + 4 + msgp.StringPrefixSize + len(z.BID) + 4 + msgp.StringPrefixSize + len(z.RID) + 4 + msgp.StringPrefixSize + len(z.TID) + 6 + msgp.StringPrefixSize + len(z.Topic) + 5 + msgp.StringPrefixSize + len(z.Channel) + 4 + msgp.StringPrefixSize + len(z.Nav) + 4 + msgp.Int64Size + 4 + msgp.Int64Size + 3 + msgp.UintSize + 4 + msgp.MapHeaderSize
+	if z.Headers != nil {
+		for zdnj, zena := range z.Headers {
+			_ = zena
+			s += msgp.StringPrefixSize + len(zdnj) + msgp.StringPrefixSize + len(zena)
+		}
+	}
+	s += 5 + msgp.GuessSize(z.Data) + 5 + msgp.StringPrefixSize + len(z.Code)
+	return
+}
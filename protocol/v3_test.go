@@ -0,0 +1,42 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chashu-code/micro-broker/manage"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_V3Protocol(t *testing.T) {
+	msg := &manage.Msg{
+		Action:    manage.ActReq,
+		RID:       "1|a",
+		Topic:     "test",
+		DeliverAt: 90,
+		Headers:   map[string]string{"baggage-uid": "7"},
+		Data:      "hello",
+		V:         VersionMsDeadline,
+	}
+	msg.SetTimeout(300 * time.Millisecond)
+
+	bts, err := NewV3Protocol().MsgToBytes(msg)
+	assert.Nil(t, err)
+
+	msgNew, err := NewV3Protocol().BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, msg, msgNew)
+	assert.False(t, msgNew.IsDead())
+
+	// 旧版本无法识别毫秒字段，视为已过期，而非按秒误判
+	msgV2, err := NewV2Protocol().BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), msgV2.DeadLine)
+	assert.True(t, msgV2.IsDead())
+
+	// 转为 V2 投递，时间以秒传输
+	bts, _ = NewV2Protocol().MsgToBytes(msgNew)
+	msgV2, _ = NewV2Protocol().BytesToMsg(bts)
+	assert.Equal(t, msg.DeadLine/1000*1000+999, msgV2.DeadLine)
+	assert.Equal(t, int64(90), msgV2.DeliverAt)
+}
@@ -0,0 +1,93 @@
+package protocol
+
+import "github.com/chashu-code/micro-broker/manage"
+
+//go:generate msgp
+
+// V4Protocol 在 V3 的基础上增加 Job 选项，取代 Code 中的 "pri|delay|ttr"；DeliverAt 同 V3 仍为 unix 秒
+type V4Protocol struct {
+	Action    string `msg:"act"`
+	BID       string `msg:"bid"`
+	RID       string `msg:"rid"`
+	TID       string `msg:"tid"`
+	Topic     string `msg:"topic"`
+	Channel   string `msg:"chan"`
+	Nav       string `msg:"nav"`
+	SendTime  int64  `msg:"stm"`
+	DeadLine  int64  `msg:"dlm"`
+	DeliverAt uint   `msg:"da"`
+
+	Headers map[string]string `msg:"hdr"`
+	Job     *V4JobOpts        `msg:"job"`
+
+	Data interface{} `msg:"data"`
+	Code string      `msg:"code"`
+}
+
+// V4JobOpts Job 选项，字段与 manage.JobOpts 一致
+type V4JobOpts struct {
+	Pri    *int64 `msg:"pri"`
+	Delay  *int64 `msg:"delay"`
+	TTR    *int64 `msg:"ttr"`
+	Tube   string `msg:"tube"`
+	Unique string `msg:"uniq"`
+}
+
+func NewV4Protocol() manage.IProtocol {
+	return &V4Protocol{}
+}
+
+func (p *V4Protocol) BytesToMsg(bts []byte) (*manage.Msg, error) {
+	*p = V4Protocol{}
+	_, err := p.UnmarshalMsg(bts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &manage.Msg{
+		Action:    p.Action,
+		BID:       p.BID,
+		RID:       p.RID,
+		TID:       p.TID,
+		Topic:     p.Topic,
+		Channel:   p.Channel,
+		Nav:       p.Nav,
+		SendTime:  p.SendTime,
+		DeadLine:  p.DeadLine,
+		DeliverAt: int64(p.DeliverAt),
+		Headers:   p.Headers,
+		Data:      p.Data,
+		Code:      p.Code,
+		V:         VersionJobOpts,
+	}
+	if p.Job != nil {
+		opts := manage.JobOpts(*p.Job)
+		msg.Job = &opts
+	}
+
+	return msg, nil
+}
+
+func (p *V4Protocol) MsgToBytes(msg *manage.Msg) ([]byte, error) {
+	p.Action = msg.Action
+	p.BID = msg.BID
+	p.RID = msg.RID
+	p.TID = msg.TID
+	p.Topic = msg.Topic
+	p.Channel = msg.Channel
+	p.Nav = msg.Nav
+	p.Code = msg.Code
+	p.Data = msg.Data
+	p.SendTime = msg.SendTime
+	p.DeadLine = msg.DeadLine
+	p.DeliverAt = uint(msg.DeliverAt)
+	p.Headers = msg.Headers
+	p.Job = nil
+	if msg.Job != nil {
+		opts := V4JobOpts(*msg.Job)
+		p.Job = &opts
+	}
+
+	return p.MarshalMsg(nil)
+}
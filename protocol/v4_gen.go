@@ -0,0 +1,796 @@
+package protocol
+
+// NOTE: THIS FILE WAS PRODUCED BY THE
+// MSGP CODE GENERATION TOOL (github.com/tinylib/msgp)
+// DO NOT EDIT
+
+import "github.com/tinylib/msgp/msgp"
+
+// DecodeMsg implements msgp.Decodable
+func (z *V4JobOpts) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zhjd uint32
+	zhjd, err = dc.ReadMapHeader()
+	if err != nil {
+		return
+	}
+	for zhjd > 0 {
+		zhjd--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "pri":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					return
+				}
+				z.Pri = nil
+			} else {
+				if z.Pri == nil {
+					z.Pri = new(int64)
+				}
+				*z.Pri, err = dc.ReadInt64()
+				if err != nil {
+					return
+				}
+			}
+		case "delay":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					return
+				}
+				z.Delay = nil
+			} else {
+				if z.Delay == nil {
+					z.Delay = new(int64)
+				}
+				*z.Delay, err = dc.ReadInt64()
+				if err != nil {
+					return
+				}
+			}
+		case "ttr":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					return
+				}
+				z.TTR = nil
+			} else {
+				if z.TTR == nil {
+					z.TTR = new(int64)
+				}
+				*z.TTR, err = dc.ReadInt64()
+				if err != nil {
+					return
+				}
+			}
+		case "tube":
+			z.Tube, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "uniq":
+			z.Unique, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *V4JobOpts) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 5
+	// write "pri"
+	err = en.Append(0x85, 0xa3, 0x70, 0x72, 0x69)
+	if err != nil {
+		return err
+	}
+	if z.Pri == nil {
+		err = en.WriteNil()
+		if err != nil {
+			return
+		}
+	} else {
+		err = en.WriteInt64(*z.Pri)
+		if err != nil {
+			return
+		}
+	}
+	// write "delay"
+	err = en.Append(0xa5, 0x64, 0x65, 0x6c, 0x61, 0x79)
+	if err != nil {
+		return err
+	}
+	if z.Delay == nil {
+		err = en.WriteNil()
+		if err != nil {
+			return
+		}
+	} else {
+		err = en.WriteInt64(*z.Delay)
+		if err != nil {
+			return
+		}
+	}
+	// write "ttr"
+	err = en.Append(0xa3, 0x74, 0x74, 0x72)
+	if err != nil {
+		return err
+	}
+	if z.TTR == nil {
+		err = en.WriteNil()
+		if err != nil {
+			return
+		}
+	} else {
+		err = en.WriteInt64(*z.TTR)
+		if err != nil {
+			return
+		}
+	}
+	// write "tube"
+	err = en.Append(0xa4, 0x74, 0x75, 0x62, 0x65)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Tube)
+	if err != nil {
+		return
+	}
+	// write "uniq"
+	err = en.Append(0xa4, 0x75, 0x6e, 0x69, 0x71)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Unique)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *V4JobOpts) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 5
+	// string "pri"
+	o = append(o, 0x85, 0xa3, 0x70, 0x72, 0x69)
+	if z.Pri == nil {
+		o = msgp.AppendNil(o)
+	} else {
+		o = msgp.AppendInt64(o, *z.Pri)
+	}
+	// string "delay"
+	o = append(o, 0xa5, 0x64, 0x65, 0x6c, 0x61, 0x79)
+	if z.Delay == nil {
+		o = msgp.AppendNil(o)
+	} else {
+		o = msgp.AppendInt64(o, *z.Delay)
+	}
+	// string "ttr"
+	o = append(o, 0xa3, 0x74, 0x74, 0x72)
+	if z.TTR == nil {
+		o = msgp.AppendNil(o)
+	} else {
+		o = msgp.AppendInt64(o, *z.TTR)
+	}
+	// string "tube"
+	o = append(o, 0xa4, 0x74, 0x75, 0x62, 0x65)
+	o = msgp.AppendString(o, z.Tube)
+	// string "uniq"
+	o = append(o, 0xa4, 0x75, 0x6e, 0x69, 0x71)
+	o = msgp.AppendString(o, z.Unique)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *V4JobOpts) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zjzy uint32
+	zjzy, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		return
+	}
+	for zjzy > 0 {
+		zjzy--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "pri":
+			if msgp.IsNil(bts) {
+				bts, err = msgp.ReadNilBytes(bts)
+				if err != nil {
+					return
+				}
+				z.Pri = nil
+			} else {
+				if z.Pri == nil {
+					z.Pri = new(int64)
+				}
+				*z.Pri, bts, err = msgp.ReadInt64Bytes(bts)
+				if err != nil {
+					return
+				}
+			}
+		case "delay":
+			if msgp.IsNil(bts) {
+				bts, err = msgp.ReadNilBytes(bts)
+				if err != nil {
+					return
+				}
+				z.Delay = nil
+			} else {
+				if z.Delay == nil {
+					z.Delay = new(int64)
+				}
+				*z.Delay, bts, err = msgp.ReadInt64Bytes(bts)
+				if err != nil {
+					return
+				}
+			}
+		case "ttr":
+			if msgp.IsNil(bts) {
+				bts, err = msgp.ReadNilBytes(bts)
+				if err != nil {
+					return
+				}
+				z.TTR = nil
+			} else {
+				if z.TTR == nil {
+					z.TTR = new(int64)
+				}
+				*z.TTR, bts, err = msgp.ReadInt64Bytes(bts)
+				if err != nil {
+					return
+				}
+			}
+		case "tube":
+			z.Tube, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "uniq":
+			z.Unique, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *V4JobOpts) Msgsize() (s int) {
+	s = 1 + 4
+	if z.Pri == nil {
+		s += msgp.NilSize
+	} else {
+		s += msgp.Int64Size
+	}
+	s += 6
+	if z.Delay == nil {
+		s += msgp.NilSize
+	} else {
+		s += msgp.Int64Size
+	}
+	s += 4
+	if z.TTR == nil {
+		s += msgp.NilSize
+	} else {
+		s += msgp.Int64Size
+	}
+	s += 5 + msgp.StringPrefixSize + len(z.Tube) + 5 + msgp.StringPrefixSize + len(z.Unique)
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *V4Protocol) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var ziga uint32
+	ziga, err = dc.ReadMapHeader()
+	if err != nil {
+		return
+	}
+	for ziga > 0 {
+		ziga--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "act":
+			z.Action, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "bid":
+			z.BID, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "rid":
+			z.RID, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "tid":
+			z.TID, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "topic":
+			z.Topic, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "chan":
+			z.Channel, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "nav":
+			z.Nav, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "stm":
+			z.SendTime, err = dc.ReadInt64()
+			if err != nil {
+				return
+			}
+		case "dlm":
+			z.DeadLine, err = dc.ReadInt64()
+			if err != nil {
+				return
+			}
+		case "da":
+			z.DeliverAt, err = dc.ReadUint()
+			if err != nil {
+				return
+			}
+		case "hdr":
+			var zuxl uint32
+			zuxl, err = dc.ReadMapHeader()
+			if err != nil {
+				return
+			}
+			if z.Headers == nil {
+				z.Headers = make(map[string]string, zuxl)
+			} else if len(z.Headers) > 0 {
+				for key := range z.Headers {
+					delete(z.Headers, key)
+				}
+			}
+			for zuxl > 0 {
+				zuxl--
+				var za0001 string
+				var za0002 string
+				za0001, err = dc.ReadString()
+				if err != nil {
+					return
+				}
+				za0002, err = dc.ReadString()
+				if err != nil {
+					return
+				}
+				z.Headers[za0001] = za0002
+			}
+		case "job":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					return
+				}
+				z.Job = nil
+			} else {
+				if z.Job == nil {
+					z.Job = new(V4JobOpts)
+				}
+				err = z.Job.DecodeMsg(dc)
+				if err != nil {
+					return
+				}
+			}
+		case "data":
+			z.Data, err = dc.ReadIntf()
+			if err != nil {
+				return
+			}
+		case "code":
+			z.Code, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *V4Protocol) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 14
+	// write "act"
+	err = en.Append(0x8e, 0xa3, 0x61, 0x63, 0x74)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Action)
+	if err != nil {
+		return
+	}
+	// write "bid"
+	err = en.Append(0xa3, 0x62, 0x69, 0x64)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.BID)
+	if err != nil {
+		return
+	}
+	// write "rid"
+	err = en.Append(0xa3, 0x72, 0x69, 0x64)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.RID)
+	if err != nil {
+		return
+	}
+	// write "tid"
+	err = en.Append(0xa3, 0x74, 0x69, 0x64)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.TID)
+	if err != nil {
+		return
+	}
+	// write "topic"
+	err = en.Append(0xa5, 0x74, 0x6f, 0x70, 0x69, 0x63)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Topic)
+	if err != nil {
+		return
+	}
+	// write "chan"
+	err = en.Append(0xa4, 0x63, 0x68, 0x61, 0x6e)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Channel)
+	if err != nil {
+		return
+	}
+	// write "nav"
+	err = en.Append(0xa3, 0x6e, 0x61, 0x76)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Nav)
+	if err != nil {
+		return
+	}
+	// write "stm"
+	err = en.Append(0xa3, 0x73, 0x74, 0x6d)
+	if err != nil {
+		return err
+	}
+	err = en.WriteInt64(z.SendTime)
+	if err != nil {
+		return
+	}
+	// write "dlm"
+	err = en.Append(0xa3, 0x64, 0x6c, 0x6d)
+	if err != nil {
+		return err
+	}
+	err = en.WriteInt64(z.DeadLine)
+	if err != nil {
+		return
+	}
+	// write "da"
+	err = en.Append(0xa2, 0x64, 0x61)
+	if err != nil {
+		return err
+	}
+	err = en.WriteUint(z.DeliverAt)
+	if err != nil {
+		return
+	}
+	// write "hdr"
+	err = en.Append(0xa3, 0x68, 0x64, 0x72)
+	if err != nil {
+		return err
+	}
+	err = en.WriteMapHeader(uint32(len(z.Headers)))
+	if err != nil {
+		return
+	}
+	for za0001, za0002 := range z.Headers {
+		err = en.WriteString(za0001)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(za0002)
+		if err != nil {
+			return
+		}
+	}
+	// write "job"
+	err = en.Append(0xa3, 0x6a, 0x6f, 0x62)
+	if err != nil {
+		return err
+	}
+	if z.Job == nil {
+		err = en.WriteNil()
+		if err != nil {
+			return
+		}
+	} else {
+		err = z.Job.EncodeMsg(en)
+		if err != nil {
+			return
+		}
+	}
+	// write "data"
+	err = en.Append(0xa4, 0x64, 0x61, 0x74, 0x61)
+	if err != nil {
+		return err
+	}
+	err = en.WriteIntf(z.Data)
+	if err != nil {
+		return
+	}
+	// write "code"
+	err = en.Append(0xa4, 0x63, 0x6f, 0x64, 0x65)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Code)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *V4Protocol) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 14
+	// string "act"
+	o = append(o, 0x8e, 0xa3, 0x61, 0x63, 0x74)
+	o = msgp.AppendString(o, z.Action)
+	// string "bid"
+	o = append(o, 0xa3, 0x62, 0x69, 0x64)
+	o = msgp.AppendString(o, z.BID)
+	// string "rid"
+	o = append(o, 0xa3, 0x72, 0x69, 0x64)
+	o = msgp.AppendString(o, z.RID)
+	// string "tid"
+	o = append(o, 0xa3, 0x74, 0x69, 0x64)
+	o = msgp.AppendString(o, z.TID)
+	// string "topic"
+	o = append(o, 0xa5, 0x74, 0x6f, 0x70, 0x69, 0x63)
+	o = msgp.AppendString(o, z.Topic)
+	// string "chan"
+	o = append(o, 0xa4, 0x63, 0x68, 0x61, 0x6e)
+	o = msgp.AppendString(o, z.Channel)
+	// string "nav"
+	o = append(o, 0xa3, 0x6e, 0x61, 0x76)
+	o = msgp.AppendString(o, z.Nav)
+	// string "stm"
+	o = append(o, 0xa3, 0x73, 0x74, 0x6d)
+	o = msgp.AppendInt64(o, z.SendTime)
+	// string "dlm"
+	o = append(o, 0xa3, 0x64, 0x6c, 0x6d)
+	o = msgp.AppendInt64(o, z.DeadLine)
+	// string "da"
+	o = append(o, 0xa2, 0x64, 0x61)
+	o = msgp.AppendUint(o, z.DeliverAt)
+	// string "hdr"
+	o = append(o, 0xa3, 0x68, 0x64, 0x72)
+	o = msgp.AppendMapHeader(o, uint32(len(z.Headers)))
+	for za0001, za0002 := range z.Headers {
+		o = msgp.AppendString(o, za0001)
+		o = msgp.AppendString(o, za0002)
+	}
+	// string "job"
+	o = append(o, 0xa3, 0x6a, 0x6f, 0x62)
+	if z.Job == nil {
+		o = msgp.AppendNil(o)
+	} else {
+		o, err = z.Job.MarshalMsg(o)
+		if err != nil {
+			return
+		}
+	}
+	// string "data"
+	o = append(o, 0xa4, 0x64, 0x61, 0x74, 0x61)
+	o, err = msgp.AppendIntf(o, z.Data)
+	if err != nil {
+		return
+	}
+	// string "code"
+	o = append(o, 0xa4, 0x63, 0x6f, 0x64, 0x65)
+	o = msgp.AppendString(o, z.Code)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *V4Protocol) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zrja uint32
+	zrja, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		return
+	}
+	for zrja > 0 {
+		zrja--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "act":
+			z.Action, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "bid":
+			z.BID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "rid":
+			z.RID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "tid":
+			z.TID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "topic":
+			z.Topic, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "chan":
+			z.Channel, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "nav":
+			z.Nav, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "stm":
+			z.SendTime, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				return
+			}
+		case "dlm":
+			z.DeadLine, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				return
+			}
+		case "da":
+			z.DeliverAt, bts, err = msgp.ReadUintBytes(bts)
+			if err != nil {
+				return
+			}
+		case "hdr":
+			var ztjn uint32
+			ztjn, bts, err = msgp.ReadMapHeaderBytes(bts)
+			if err != nil {
+				return
+			}
+			if z.Headers == nil {
+				z.Headers = make(map[string]string, ztjn)
+			} else if len(z.Headers) > 0 {
+				for key := range z.Headers {
+					delete(z.Headers, key)
+				}
+			}
+			for ztjn > 0 {
+				var za0001 string
+				var za0002 string
+				ztjn--
+				za0001, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					return
+				}
+				za0002, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					return
+				}
+				z.Headers[za0001] = za0002
+			}
+		case "job":
+			if msgp.IsNil(bts) {
+				bts, err = msgp.ReadNilBytes(bts)
+				if err != nil {
+					return
+				}
+				z.Job = nil
+			} else {
+				if z.Job == nil {
+					z.Job = new(V4JobOpts)
+				}
+				bts, err = z.Job.UnmarshalMsg(bts)
+				if err != nil {
+					return
+				}
+			}
+		case "data":
+			z.Data, bts, err = msgp.ReadIntfBytes(bts)
+			if err != nil {
+				return
+			}
+		case "code":
+			z.Code, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *V4Protocol) Msgsize() (s int) {
+	s = 1 + 4 + msgp.StringPrefixSize + len(z.Action) + 4 + msgp.StringPrefixSize + len(z.BID) + 4 + msgp.StringPrefixSize + len(z.RID) + 4 + msgp.StringPrefixSize + len(z.TID) + 6 + msgp.StringPrefixSize + len(z.Topic) + 5 + msgp.StringPrefixSize + len(z.Channel) + 4 + msgp.StringPrefixSize + len(z.Nav) + 4 + msgp.Int64Size + 4 + msgp.Int64Size + 3 + msgp.UintSize + 4 + msgp.MapHeaderSize
+	if z.Headers != nil {
+		for za0001, za0002 := range z.Headers {
+			_ = za0002
+			s += msgp.StringPrefixSize + len(za0001) + msgp.StringPrefixSize + len(za0002)
+		}
+	}
+	s += 4
+	if z.Job == nil {
+		s += msgp.NilSize
+	} else {
+		s += z.Job.Msgsize()
+	}
+	s += 5 + msgp.GuessSize(z.Data) + 5 + msgp.StringPrefixSize + len(z.Code)
+	return
+}
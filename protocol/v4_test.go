@@ -0,0 +1,43 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/chashu-code/micro-broker/manage"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_V4Protocol(t *testing.T) {
+	pri := int64(0)
+	msg := &manage.Msg{
+		Action:   manage.ActJob,
+		RID:      "1|a",
+		Topic:    "test",
+		SendTime: 100500,
+		DeadLine: 110500,
+		Headers:  map[string]string{"baggage-uid": "7"},
+		Job:      &manage.JobOpts{Pri: &pri, Tube: "other", Unique: "u"},
+		Data:     "hello",
+		V:        VersionJobOpts,
+	}
+
+	bts, err := NewV4Protocol().MsgToBytes(msg)
+	assert.Nil(t, err)
+
+	p := NewV4Protocol()
+	msgNew, err := p.BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, msg, msgNew)
+	assert.Nil(t, msgNew.Job.Delay)
+
+	// 复用时不残留上次的 Job 选项
+	msg.Job = nil
+	bts, _ = NewV4Protocol().MsgToBytes(msg)
+	msgNew, _ = p.BytesToMsg(bts)
+	assert.Nil(t, msgNew.Job)
+
+	// V3 可解析 V4 内容（ 忽略 Job 选项 ）
+	msgV3, err := NewV3Protocol().BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(110500), msgV3.DeadLine)
+}
@@ -0,0 +1,25 @@
+package protocol
+
+// 协议版本号，即打包后消息的首字节（ 见 manage.Manager.Pack ），按协议加入的先后分配；
+// 常量以协议特性命名，与协议类型名中的序号无关（ 如 V3Protocol 的版本号为 5 ）：
+//
+//	1  V1Protocol    msgpack，st、dl 为 unix 秒
+//	2  V2Protocol    VersionHeaders     V1 + hdr、da
+//	3  ZipProtocol   VersionZip         1 字节压缩标记 + V1
+//	4  JSONProtocol  VersionJSON        JSON，字段同 V2
+//	5  V3Protocol    VersionMsDeadline  V2，st、dl 改为 unix 毫秒的 stm、dlm
+//	6  V4Protocol    VersionJobOpts     V3 + job
+//
+// 各版本的 da（ DeliverAt ）均为 unix 秒
+const (
+	// VersionHeaders 带 Headers 的协议版本号
+	VersionHeaders = 2
+	// VersionZip 压缩协议版本号
+	VersionZip = 3
+	// VersionJSON JSON 协议版本号
+	VersionJSON = 4
+	// VersionMsDeadline 毫秒时间的协议版本号
+	VersionMsDeadline = 5
+	// VersionJobOpts 带 Job 选项的协议版本号
+	VersionJobOpts = 6
+)
@@ -0,0 +1,104 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/chashu-code/micro-broker/defaults"
+	"github.com/chashu-code/micro-broker/manage"
+)
+
+const (
+	zipFlagNone = 0
+	zipFlagGzip = 1
+
+	// zipMaxSize 解压后的最大字节数，避免异常数据耗尽内存
+	zipMaxSize = 64 << 20
+)
+
+// ZipProtocol 压缩协议：1 字节压缩标记（ 0 未压缩，1 gzip ） + V1 msgpack 内容。
+// 为兼容已有的压缩客户端，内容固定为 V1：Headers、DeliverAt、Job 选项不随之传输，
+// 时间精度为秒；转换至该协议丢失字段时由 CarryWorker 计入 translate.lossy
+type ZipProtocol struct {
+	v1 V1Protocol
+	// minSize 超过该字节数的消息才压缩
+	minSize int
+}
+
+// NewZipProtocol 构造压缩协议，以默认的 defaults.DefaultZipMinSize 为压缩阈值
+func NewZipProtocol() manage.IProtocol {
+	return &ZipProtocol{minSize: defaults.DefaultZipMinSize}
+}
+
+// ZipProtocolGen 返回以 minSize 为压缩阈值的压缩协议构造方法（ 即 Config.ZipMinSize ）
+func ZipProtocolGen(minSize int) manage.ProtocolGenFn {
+	return func() manage.IProtocol {
+		return &ZipProtocol{minSize: minSize}
+	}
+}
+
+// BytesToMsg bytes => msg
+func (p *ZipProtocol) BytesToMsg(bts []byte) (*manage.Msg, error) {
+	if len(bts) < 1 {
+		return nil, errors.New("zip protocol need compress flag")
+	}
+
+	body := bts[1:]
+	switch bts[0] {
+	case zipFlagNone:
+	case zipFlagGzip:
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		body, err = ioutil.ReadAll(io.LimitReader(r, zipMaxSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > zipMaxSize {
+			return nil, errors.New("zip protocol body too large")
+		}
+	default:
+		return nil, errors.New("zip protocol with unknown compress flag")
+	}
+
+	msg, err := p.v1.BytesToMsg(body)
+	if err != nil {
+		return nil, err
+	}
+	msg.V = VersionZip
+	return msg, nil
+}
+
+// MsgToBytes msg => bytes，超过压缩阈值则以 gzip 压缩
+func (p *ZipProtocol) MsgToBytes(msg *manage.Msg) ([]byte, error) {
+	body, err := p.v1.MsgToBytes(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) <= p.minSize {
+		return append([]byte{zipFlagNone}, body...), nil
+	}
+
+	var buff bytes.Buffer
+	buff.WriteByte(zipFlagGzip)
+
+	w, err := gzip.NewWriterLevel(&buff, gzip.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(body); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
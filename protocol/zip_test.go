@@ -0,0 +1,65 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chashu-code/micro-broker/manage"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ZipProtocol(t *testing.T) {
+	p := NewZipProtocol()
+	msg := &manage.Msg{
+		Action: manage.ActReq,
+		RID:    "1|a",
+		Topic:  "test",
+		Data:   "small",
+		V:      VersionZip,
+	}
+
+	// 未超过压缩阈值，不压缩
+	bts, err := p.MsgToBytes(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(zipFlagNone), bts[0])
+
+	msgNew, err := NewZipProtocol().BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, "small", msgNew.Data)
+	assert.Equal(t, uint(VersionZip), msgNew.V)
+
+	// 超过则压缩
+	data := strings.Repeat("big payload ", 1000)
+	msg.Data = data
+	bts, err = p.MsgToBytes(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(zipFlagGzip), bts[0])
+	assert.True(t, len(bts) < len(data))
+
+	msgNew, err = NewZipProtocol().BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, data, msgNew.Data)
+	assert.Equal(t, "1|a", msgNew.RID)
+	assert.Equal(t, "test", msgNew.Topic)
+
+	// 压缩阈值可配置
+	bts, err = ZipProtocolGen(len(bts) * 100)().MsgToBytes(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(zipFlagNone), bts[0])
+
+	// 内容为 V1：Headers、DeliverAt 不传输
+	msg.Headers = map[string]string{"baggage-uid": "7"}
+	msg.DeliverAt = 1
+	bts, err = p.MsgToBytes(msg)
+	assert.Nil(t, err)
+	msgNew, err = p.BytesToMsg(bts)
+	assert.Nil(t, err)
+	assert.Empty(t, msgNew.Headers)
+	assert.Equal(t, int64(0), msgNew.DeliverAt)
+
+	// 异常数据
+	for _, wrong := range [][]byte{{}, {9, 1}, {zipFlagGzip, 1, 2}} {
+		_, err = NewZipProtocol().BytesToMsg(wrong)
+		assert.NotNil(t, err)
+	}
+}
@@ -1,19 +1,38 @@
 package work
 
 import (
+	"errors"
 	"strconv"
-
-	rxpool "github.com/mediocregopher/radix.v2/pool"
+	"strings"
+	"time"
 
 	"github.com/chashu-code/micro-broker/defaults"
 	"github.com/chashu-code/micro-broker/manage"
 	"github.com/chashu-code/micro-broker/pool"
+	rxpool "github.com/mediocregopher/radix.v2/pool"
+	"github.com/mediocregopher/radix.v2/redis"
 	"github.com/uber-go/zap"
 )
 
+var errBreakerOpen = errors.New("breaker is open")
+
 // CarryWorker 消息搬运工作器
 type CarryWorker struct {
 	Worker
+	// pushes 批量处理时，待推送的消息（ destIP => items ），为 nil 则立即推送
+	pushes map[string][]*pushItem
+	// drops 协议版本转换（ "from-to" ）后无法携带的字段，首次转换时检测
+	drops map[string][]string
+}
+
+// pushFailFn 推送失败回调
+type pushFailFn func(err error)
+
+type pushItem struct {
+	key    string
+	msg    *manage.Msg
+	bts    []byte
+	onFail pushFailFn
 }
 
 // CarryWorkerRun 运行多个CarryWorker
@@ -29,8 +48,30 @@ func (w *CarryWorker) logMsg(info string, msg *manage.Msg) {
 }
 
 func (w *CarryWorker) process() {
-	msg, ok := w.mgr.MsgQ.Pop(true)
-	if !ok {
+	durFlush := time.Duration(w.mgr.Conf.CarryFlushMSecs) * time.Millisecond
+	msgs := w.mgr.MsgQ.PopBatch(w.mgr.Conf.CarryBatchSize, durFlush)
+	if len(msgs) == 0 {
+		return
+	}
+
+	// 批量处理：同一目标的推送合并为 pipeline 一并发送
+	if len(msgs) > 1 {
+		w.pushes = make(map[string][]*pushItem)
+	}
+
+	for _, msg := range msgs {
+		w.carry(msg)
+	}
+	w.flush()
+
+	// 处理完成（ 成功投递或已存入死信队列 ）后确认
+	for _, msg := range msgs {
+		w.ack(msg.Ack)
+	}
+}
+
+func (w *CarryWorker) carry(msg *manage.Msg) {
+	if w.delay(msg) || w.isDuplicate(msg) {
 		return
 	}
 
@@ -41,17 +82,167 @@ func (w *CarryWorker) process() {
 		w.processRes("res <<---", msg)
 	case manage.ActJob:
 		w.processJob("job --->>", msg)
+	case manage.ActPing, manage.ActEcho, manage.ActInfo:
+		w.processBuiltin(msg.Action+" --->>", msg)
+	case manage.ActReg, manage.ActUnreg:
+		w.processReg(msg.Action+" --->>", msg)
+	case manage.ActJobDelete, manage.ActJobKick, manage.ActJobPeek, manage.ActJobBury, manage.ActJobStats:
+		w.processJobCtl(msg.Action+" --->>", msg)
 	default:
 		w.logMsg("?? --- can't carry", msg)
 	}
 }
 
+// delay 未到投递时间的请求，存入本机 redis sorted set，由 DelayWorker 到期后放回 MsgQ
+func (w *CarryWorker) delay(msg *manage.Msg) bool {
+	if msg.Action != manage.ActReq || msg.IsDue() {
+		return false
+	}
+
+	msg.FillWithReq(w.mgr)
+	w.logMsg("req delayed", msg)
+
+	bts, err := w.mgr.Pack(msg)
+	if err == nil {
+		var p *rxpool.Pool
+		if p, _, err = w.redisPoolMap.FetchOrNew(defaults.IPLocal, w.mgr.Conf.PoolSize); err == nil {
+			err = p.Cmd("zadd", w.mgr.DelayName(), msg.DeliverAt, bts).Err
+		}
+	}
+
+	if err != nil {
+		w.Log.Error("delay req fail", zap.Error(err), msgPackField(msg))
+		w.replyErr(msg, "500", "delay req fail:"+err.Error())
+		return true
+	}
+
+	w.mgr.Counter.Incr("delay." + msg.Topic)
+	return true
+}
+
+// isDuplicate 时间窗口内重复的请求或 Job（ 如客户端超时重试 ），直接丢弃
+func (w *CarryWorker) isDuplicate(msg *manage.Msg) bool {
+	if msg.Action != manage.ActReq && msg.Action != manage.ActJob {
+		return false
+	}
+
+	seen, err := w.mgr.Dedup.Seen(msg)
+	if err != nil {
+		w.Log.Warn("shared dedup fail, allow", zap.Error(err))
+	}
+
+	if seen {
+		w.mgr.Counter.Incr("dedup." + msg.Action)
+		w.logMsg("duplicate msg, drop", msg)
+	}
+	return seen
+}
+
 func (w *CarryWorker) processReq(log string, msg *manage.Msg) {
-	// TODO router / connect
 	msg.FillWithReq(w.mgr)
-	msg.BID = defaults.IPLocal // 暂时仅支持本机
 	w.logMsg(log, msg)
-	w.pushMsg(defaults.IPLocal, msg.Topic, msg)
+
+	if !w.allowReq(msg) {
+		return
+	}
+
+	if w.mgr.Conf.RegistryStrict && !w.mgr.Registry.IsRegistered(msg) {
+		w.replyErr(msg, manage.CodeNotFound, "service "+msg.ServiceName()+" unregistered")
+		return
+	}
+
+	destIP := defaults.IPLocal
+	node := w.mgr.Router.Route(msg)
+	switch {
+	case node != nil && !w.mgr.IsLocal(node.IP):
+		destIP = node.IP
+	case node == nil && w.mgr.Conf.RegistryRoute:
+		destIP = w.registryIP(msg)
+	}
+
+	if !w.mgr.IsLocal(destIP) {
+		// 熔断中，不再尝试链接
+		if w.mgr.Breakers.IsOpen(destIP) {
+			w.mgr.Pending.Add(msg, node)
+			w.replyUnavailable(destIP, msg)
+			return
+		}
+
+		// 远端服务：链接目标 redis（首次链接会启动对应的 SubWorker）
+		if _, err := w.mgr.ConnectRedis(destIP); err != nil {
+			w.mgr.Breakers.Failure(destIP)
+			w.Log.Error("connect "+destIP+" redis fail", zap.Error(err), msgPackField(msg))
+			w.mgr.Pending.Add(msg, node)
+			w.replyUnavailable(destIP, msg)
+			return
+		}
+	}
+
+	// 先记录再投递，避免应答先于记录到达
+	w.mgr.Pending.Add(msg, node)
+	// 投递失败（ 熔断、推送或打包失败 ）则应答不可用，由 processRes 清理等待记录
+	w.pushMsg(destIP, msg.Topic, msg, func(err error) {
+		w.replyUnavailable(destIP, msg)
+	})
+}
+
+// allowReq 检测限流，超限则直接应答，不再投递
+func (w *CarryWorker) allowReq(msg *manage.Msg) bool {
+	name, err := w.mgr.Limiter.Allow(msg)
+	if err != nil {
+		w.Log.Warn("shared limit fail, allow", zap.Error(err))
+	}
+
+	if name == "" {
+		return true
+	}
+
+	w.mgr.Counter.Incr("limit." + name)
+	w.replyErr(msg, manage.CodeTooMany, "rate limit exceeded: "+name)
+	return false
+}
+
+// registryIP 未配置路由时，投递至已注册该服务的 broker（ 本机已注册则投递本机 ）
+func (w *CarryWorker) registryIP(msg *manage.Msg) string {
+	for _, e := range w.mgr.Registry.Lookup(msg) {
+		if w.mgr.IsLocal(e.Host) {
+			return defaults.IPLocal
+		}
+	}
+
+	if ip := w.mgr.Registry.Pick(msg); ip != "" {
+		return ip
+	}
+	return defaults.IPLocal
+}
+
+// replyErr 直接应答请求错误，不再投递
+func (w *CarryWorker) replyErr(msg *manage.Msg, code, reason string) {
+	msgRes := msg.Clone(manage.ActRes)
+	msgRes.Code = code
+	msgRes.Data = reason
+	w.processRes("err res <<---", msgRes)
+}
+
+// claimJob Job 的 Data 过大则存入本机 redis（ 至少保留 keep ），返回 Data 替换为凭证的副本；未替换或失败则返回 msg（ 仍 Put 原数据 ）
+func (w *CarryWorker) claimJob(msg *manage.Msg, keep time.Duration) *manage.Msg {
+	msgClaim := *msg
+	ok, err := w.mgr.Claims.Store(defaults.IPLocal, &msgClaim, keep)
+	if err != nil {
+		w.Log.Warn("claim check fail", zap.Error(err), zap.String("tid", msg.TID))
+		return msg
+	}
+
+	if !ok {
+		return msg
+	}
+	w.mgr.Counter.Incr("claim." + msg.Action)
+	return &msgClaim
+}
+
+// replyUnavailable 目标熔断中或投递失败，直接应答请求不可用（ 经 processRes 清理等待记录 ）
+func (w *CarryWorker) replyUnavailable(destIP string, msg *manage.Msg) {
+	w.replyErr(msg, manage.CodeUnavailable, "destination "+destIP+" unavailable")
 }
 
 func (w *CarryWorker) processJob(log string, msg *manage.Msg) {
@@ -59,40 +250,298 @@ func (w *CarryWorker) processJob(log string, msg *manage.Msg) {
 	msg.FillWithReq(w.mgr)
 	w.logMsg(log, msg)
 
+	pri, delay, ttr, err := msg.PutArgs()
+	if err != nil {
+		if msg.Job != nil {
+			w.Log.Warn("put job with error opts", zap.Error(err), msgPackField(msg))
+			w.replyErr(msg, manage.CodeBadRequest, "put job fail:"+err.Error())
+			return
+		}
+		w.Log.Warn("put job code fail, use the default")
+	}
+
+	tube := msg.JobTube()
+	msgRes := msg.Clone(manage.ActRes)
+
+	if id, ok := w.jobUniqueSeen(tube, msg); ok {
+		w.mgr.Counter.Incr("job.dup")
+		// 之前的 Job 尚未 Put 完成，结果未知，由调用方重试
+		if id == 0 {
+			w.replyErr(msg, manage.CodeConflict, "put job fail:job with the same unique key is putting")
+			return
+		}
+		msgRes.Data = map[string]interface{}{
+			"id":   id,
+			"tube": tube,
+			"dup":  true,
+		}
+		w.processRes("job res <<---", msgRes)
+		return
+	}
+
+	// 避免超出 beanstalk job 大小限制
+	job := w.claimJob(msg, delay+ttr)
+
 	p, _, err := w.beanPoolMap.FetchOrNew(defaults.IPLocal, w.mgr.Conf.JobPoolSize)
 	if err != nil {
 		w.Log.Error("fetch local job pool fail", zap.Error(err))
+		w.jobUniqueSet(tube, msg, 0)
+		w.dropPutClaim(msg, job)
+		w.replyErr(msg, "500", "put job fail:"+err.Error())
 		return
 	}
 
+	var id uint64
 	err = p.With(func(c *pool.BeanClient) error {
-		pri, delay, ttr, errWith := msg.CodeToPutArgs()
+		bts, errWith := w.mgr.Pack(job)
 		if errWith != nil {
-			w.Log.Warn("put job code fail, use the default")
+			return errWith
 		}
+		id, errWith = c.Put(tube, bts, pri, delay, ttr)
+		return errWith
+	})
+	w.jobUniqueSet(tube, msg, id)
 
-		var bts []byte
-		bts, errWith = w.mgr.Pack(msg)
-		if errWith != nil {
-			return errWith
+	if err != nil {
+		w.dropPutClaim(msg, job)
+		msgRes.Code = "500"
+		msgRes.Data = "put job fail:" + err.Error()
+	} else {
+		msgRes.Data = map[string]interface{}{
+			"id":   id,
+			"tube": tube,
 		}
-		_, errWith = c.Put(msg.TubeName(), bts, pri, delay, ttr)
-		if errWith != nil {
+	}
+
+	w.processRes("job res <<---", msgRes)
+}
+
+// jobUniqueSeen 检查并占用 Job 唯一键，已存在则返回之前 Job 的 id（ 尚未 Put 完成时为 0 ）
+// redis 出错时视为不存在
+func (w *CarryWorker) jobUniqueSeen(tube string, msg *manage.Msg) (uint64, bool) {
+	if msg.Job == nil || msg.Job.Unique == "" {
+		return 0, false
+	}
+
+	p, _, err := w.redisPoolMap.FetchOrNew(defaults.IPLocal, w.mgr.Conf.PoolSize)
+	if err != nil {
+		w.Log.Warn("job unique check fail, allow", zap.Error(err))
+		return 0, false
+	}
+
+	key := w.mgr.JobUniqueName(tube, msg.Job.Unique)
+	res := p.Cmd("set", key, 0, "EX", w.mgr.Conf.JobUniqueSecs, "NX")
+	if res.Err != nil {
+		w.Log.Warn("job unique check fail, allow", zap.Error(res.Err))
+		return 0, false
+	}
+
+	// 已存在则不设置，返回 nil
+	if !res.IsType(redis.Nil) {
+		return 0, false
+	}
+
+	id, _ := p.Cmd("get", key).Int64()
+	return uint64(id), true
+}
+
+// jobUniqueSet 记录 Job 唯一键对应的 id，Put 失败（ id 为 0 ）则释放唯一键
+func (w *CarryWorker) jobUniqueSet(tube string, msg *manage.Msg, id uint64) {
+	if msg.Job == nil || msg.Job.Unique == "" {
+		return
+	}
+
+	p, _, err := w.redisPoolMap.FetchOrNew(defaults.IPLocal, w.mgr.Conf.PoolSize)
+	if err == nil {
+		key := w.mgr.JobUniqueName(tube, msg.Job.Unique)
+		if id == 0 {
+			err = p.Cmd("del", key).Err
+		} else {
+			err = p.Cmd("set", key, id, "EX", w.mgr.Conf.JobUniqueSecs).Err
+		}
+	}
+
+	if err != nil {
+		w.Log.Warn("job unique set fail", zap.Error(err))
+	}
+}
+
+// processJobCtl 管理本机 beanstalk 中的 Job，不存在时应答 404
+func (w *CarryWorker) processJobCtl(log string, msg *manage.Msg) {
+	msg.FillWithReq(w.mgr)
+	w.logMsg(log, msg)
+
+	msgRes := msg.Clone(manage.ActRes)
+
+	id, err := msg.JobID()
+	if err != nil {
+		w.Log.Warn(msg.Action+" with error id", zap.Error(err), msgPackField(msg))
+		msgRes.Code = manage.CodeBadRequest
+		msgRes.Data = msg.Action + " fail:" + err.Error()
+		w.processRes(msg.Action+" res <<---", msgRes)
+		return
+	}
+
+	// 仅 ActJobBury 使用 pri
+	pri, _, _, err := msg.PutArgs()
+	if err != nil && msg.Job != nil {
+		w.Log.Warn(msg.Action+" with error opts", zap.Error(err), msgPackField(msg))
+		msgRes.Code = manage.CodeBadRequest
+		msgRes.Data = msg.Action + " fail:" + err.Error()
+		w.processRes(msg.Action+" res <<---", msgRes)
+		return
+	}
+
+	p, _, err := w.beanPoolMap.FetchOrNew(defaults.IPLocal, w.mgr.Conf.JobPoolSize)
+	if err != nil {
+		w.Log.Error("fetch local job pool fail", zap.Error(err))
+		msgRes.Code = "500"
+		msgRes.Data = msg.Action + " fail:" + err.Error()
+		w.processRes(msg.Action+" res <<---", msgRes)
+		return
+	}
+
+	msgRes.Data = "ok"
+	err = p.With(func(c *pool.BeanClient) error {
+		switch msg.Action {
+		case manage.ActJobDelete:
+			// 先取得大数据凭证，删除 Job 后一并删除
+			body, _ := c.Peek(id)
+			errWith := c.Delete(id)
+			if errWith == nil && body != nil {
+				if job, errUnpack := w.mgr.Unpack(body); errUnpack == nil {
+					w.dropJobClaim(job)
+				}
+			}
+			return errWith
+		case manage.ActJobKick:
+			return c.Kick(id)
+		case manage.ActJobBury:
+			return pool.BuryJob(p.Addr, id, pri)
+		case manage.ActJobStats:
+			info, errWith := c.StatsJob(id)
+			msgRes.Data = info
+			return errWith
+		default: // manage.ActJobPeek
+			body, errWith := c.Peek(id)
+			if errWith == nil {
+				msgRes.Data = w.jobPeekData(id, body)
+			}
 			return errWith
 		}
-		return nil
 	})
 
+	if err != nil {
+		msgRes.Code = "500"
+		if pool.IsNotFound(err) {
+			msgRes.Code = manage.CodeNotFound
+		}
+		msgRes.Data = msg.Action + " fail:" + err.Error()
+	}
+
+	w.processRes(msg.Action+" res <<---", msgRes)
+}
+
+// dropJobClaim 删除 Job 的大数据凭证（ Put 失败或 Job 已删除 ）
+func (w *CarryWorker) dropJobClaim(job *manage.Msg) {
+	if err := w.mgr.Claims.Drop(defaults.IPLocal, manage.ClaimRef(job.Data)); err != nil {
+		w.Log.Warn("drop job claim fail", zap.Error(err), zap.String("tid", job.TID))
+	}
+}
+
+// dropPutClaim Put 失败，删除本次存入的大数据凭证（ job 即 msg 时未存入，凭证来自客户端，不删除 ）
+func (w *CarryWorker) dropPutClaim(msg, job *manage.Msg) {
+	if job != msg {
+		w.dropJobClaim(job)
+	}
+}
+
+// jobPeekData Job 内容，无法解析时原样返回
+func (w *CarryWorker) jobPeekData(id uint64, body []byte) map[string]interface{} {
+	job, err := w.mgr.Unpack(body)
+	if err != nil {
+		return map[string]interface{}{
+			"id":   id,
+			"body": body,
+		}
+	}
+
+	if _, err = w.mgr.Claims.Load(defaults.IPLocal, job); err != nil {
+		w.Log.Warn("load job claim fail", zap.Error(err))
+	}
+
+	return map[string]interface{}{
+		"id":   id,
+		"tube": job.JobTube(),
+		"tid":  job.TID,
+		"data": job.Data,
+	}
+}
+
+// processBuiltin 由 broker 直接应答的指令
+func (w *CarryWorker) processBuiltin(log string, msg *manage.Msg) {
+	msg.FillWithReq(w.mgr)
+	w.logMsg(log, msg)
+
+	msgRes := msg.Clone(manage.ActRes)
+
+	switch msg.Action {
+	case manage.ActPing:
+		msgRes.Data = "pong"
+	case manage.ActInfo:
+		msgRes.Data = w.info()
+	}
+
+	w.processRes(msg.Action+" res <<---", msgRes)
+}
+
+// info broker 信息：ip、版本、运行秒数及各队列长度
+func (w *CarryWorker) info() map[string]interface{} {
+	info := map[string]interface{}{
+		"ip":      w.mgr.IP(),
+		"version": defaults.Version,
+		"uptime":  int64(w.mgr.Uptime().Seconds()),
+		"msgq":    w.mgr.MsgQ.Len(),
+		"pending": w.mgr.Pending.Len(),
+	}
+
+	if p := w.redisPoolMap.Fetch(defaults.IPLocal); p != nil {
+		if n, err := p.Cmd("llen", w.mgr.Outbox(defaults.IPLocal)).Int(); err == nil {
+			info["outbox"] = n
+		}
+	}
+
+	if n, err := w.mgr.DLQ.Len(); err == nil {
+		info["dlq"] = n
+	}
+
+	return info
+}
+
+// processReg 服务注册（ 心跳 ）或注销，应答 "ok" 或错误原因
+func (w *CarryWorker) processReg(log string, msg *manage.Msg) {
+	msg.FillWithReq(w.mgr)
+	w.logMsg(log, msg)
+
 	msgRes := msg.Clone(manage.ActRes)
+	msgRes.Data = "ok"
+
+	e, err := manage.NewRegEntry(msg, w.mgr.Conf.RegistryTTLSecs)
+	if err == nil {
+		if msg.Action == manage.ActReg {
+			err = w.mgr.Registry.Register(e)
+		} else {
+			err = w.mgr.Registry.Unregister(e)
+		}
+	}
 
 	if err != nil {
+		w.Log.Error(msg.Action+" service fail", zap.Error(err), msgPackField(msg))
 		msgRes.Code = "500"
-		msgRes.Data = "put job fail:" + err.Error()
-	} else {
-		msgRes.Data = "ok"
+		msgRes.Data = msg.Action + " service fail:" + err.Error()
 	}
 
-	w.processRes("job res <<---", msgRes)
+	w.processRes(msg.Action+" res <<---", msgRes)
 }
 
 func (w *CarryWorker) processRes(log string, msg *manage.Msg) {
@@ -100,29 +549,243 @@ func (w *CarryWorker) processRes(log string, msg *manage.Msg) {
 	pid, err := msg.PidOfRID()
 	if err != nil {
 		w.Log.Error("get pid fail", zap.Error(err))
+		bts, _ := w.mgr.Pack(msg)
+		w.deadLetter(manage.NewDeadLetter(manage.DLStagePid, err, msg, bts))
 		return
 	}
-	w.pushMsg(defaults.IPLocal, pid, msg)
+
+	if w.mgr.IsLocal(msg.BID) {
+		// 投递至请求指定的 reply-to inbox，无对应请求时取应答中的（ broker 直接应答时由请求复制 ）
+		replyTo := msg.Headers[manage.HeaderReplyTo]
+		if req := w.mgr.Pending.Done(msg); req != nil {
+			w.mgr.Router.Done(req.Node)
+			// 以调用者请求的协议版本应答（ 如 V1 调用者、V2 服务 ）
+			msg.V = req.Msg.V
+			replyTo = req.Msg.Headers[manage.HeaderReplyTo]
+		} else if !w.checkRes(msg) {
+			return
+		}
+
+		box := pid
+		if replyTo != "" {
+			box = replyTo
+		}
+		w.pushMsg(defaults.IPLocal, box, msg, nil)
+		return
+	}
+
+	// 请求来自远端 broker：交回其 outbox，由发起方 broker 投递至调用者 inbox
+	w.push(msg.BID, w.mgr.Outbox(defaults.IPLocal), msg, func(err error) {
+		w.mgr.Counter.Incr("res.unreachable")
+		w.Log.Error("return res to "+msg.BID+" fail", zap.String("tid", msg.TID), zap.Error(err))
+	})
+}
+
+// checkRes 检测无对应请求的应答，重复或迟到（ 已应答超时 ）的应答将被丢弃
+func (w *CarryWorker) checkRes(msg *manage.Msg) bool {
+	switch w.mgr.Pending.State(msg) {
+	case manage.PendingAnswered:
+		w.mgr.Counter.Incr("res.duplicate")
+		w.Log.Warn("duplicate res, drop", msgPackField(msg))
+		return false
+	case manage.PendingExpired:
+		w.mgr.Counter.Incr("res.late")
+		w.Log.Warn("late res, drop", msgPackField(msg))
+		return false
+	}
+	return true
+}
+
+// pushMsg 推送 msg 至 destIP 的 inbox，以该 inbox 偏好的协议版本编码，失败时回调 onFail（可为 nil）
+func (w *CarryWorker) pushMsg(destIP, boxName string, msg *manage.Msg, onFail pushFailFn) {
+	w.push(destIP, w.mgr.Inbox(boxName), w.translate(boxName, msg), onFail)
+}
+
+// translate 转换为 inbox 偏好的协议版本（ 未知或未支持则不转换 ），返回新的 msg
+// 偏好版本：配置优先，其次为服务（ Topic ）已注册实例中最低的版本；应答沿用对应请求的版本
+func (w *CarryWorker) translate(boxName string, msg *manage.Msg) *manage.Msg {
+	v := w.mgr.Versions.Get(boxName)
+	if v == 0 {
+		v = w.mgr.Registry.Proto(boxName)
+	}
+	if v == 0 || v == msg.V || !w.mgr.HasProtocol(v) {
+		return msg
+	}
+
+	name := strconv.FormatUint(uint64(msg.V), 10) + "-" + strconv.FormatUint(uint64(v), 10)
+	w.mgr.Counter.Incr("translate." + name)
+
+	msgNew := *msg
+	msgNew.V = v
+
+	if translateLost(msg, w.translateDrops(name, v)) {
+		w.mgr.Counter.Incr("translate.lossy." + name)
+	}
+	return &msgNew
 }
 
-func (w *CarryWorker) pushMsg(destIP, boxName string, msg *manage.Msg) {
+// translateDrops 返回转换为协议版本 v 后无法携带的字段，每对版本仅检测一次（ 以携带各可选字段的消息打包再解析 ）
+// 时间精度降低（ 毫秒 => 秒 ）为协议本身的约定，不计入
+func (w *CarryWorker) translateDrops(name string, v uint) []string {
+	if drops, ok := w.drops[name]; ok {
+		return drops
+	}
+
+	probe := &manage.Msg{
+		Action:    manage.ActReq,
+		DeliverAt: 1,
+		Headers:   map[string]string{"x": "x"},
+		Job:       &manage.JobOpts{Tube: "x"},
+		V:         v,
+	}
+
+	drops := []string{}
+	if bts, err := w.mgr.Pack(probe); err == nil {
+		if back, err := w.mgr.Unpack(bts); err == nil {
+			if len(back.Headers) == 0 {
+				drops = append(drops, "headers")
+			}
+			if back.Job == nil {
+				drops = append(drops, "job")
+			}
+			if back.DeliverAt != probe.DeliverAt {
+				drops = append(drops, "deliver_at")
+			}
+		}
+	}
+
+	if w.drops == nil {
+		w.drops = make(map[string][]string)
+	}
+	w.drops[name] = drops
+
+	if len(drops) > 0 {
+		w.Log.Warn("translate drops fields", zap.String("translate", name), zap.String("fields", strings.Join(drops, ",")))
+	}
+	return drops
+}
+
+// translateLost msg 是否携带 drops 中的字段
+func translateLost(msg *manage.Msg, drops []string) bool {
+	for _, field := range drops {
+		switch field {
+		case "headers":
+			if len(msg.Headers) > 0 {
+				return true
+			}
+		case "job":
+			if msg.Job != nil {
+				return true
+			}
+		case "deliver_at":
+			if msg.DeliverAt != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (w *CarryWorker) push(destIP, key string, msg *manage.Msg, onFail pushFailFn) {
+	item := &pushItem{
+		key:    key,
+		msg:    msg,
+		onFail: onFail,
+	}
 
 	bts, err := w.mgr.Pack(msg)
 	if err != nil {
 		w.Log.Error("pack msg fail", zap.Error(err))
+		w.pushFail(manage.DLStagePack, err, destIP, item)
 		return
 	}
-	var pool *rxpool.Pool
-	pool, _, err = w.redisPoolMap.FetchOrNew(destIP, w.mgr.Conf.PoolSize)
+	item.bts = bts
+
+	if w.pushes != nil {
+		w.pushes[destIP] = append(w.pushes[destIP], item)
+		return
+	}
+
+	w.pipePush(destIP, []*pushItem{item})
+}
+
+// flush 发送批量处理时积累的推送
+func (w *CarryWorker) flush() {
+	pushes := w.pushes
+	w.pushes = nil
+
+	for destIP, items := range pushes {
+		w.pipePush(destIP, items)
+	}
+}
+
+// pipePush 以 pipeline 方式推送至 destIP
+func (w *CarryWorker) pipePush(destIP string, items []*pushItem) {
+	// 熔断中，直接失败
+	if !w.mgr.Breakers.Allow(destIP) {
+		w.mgr.Counter.Add("breaker.reject", len(items))
+		for _, item := range items {
+			w.pushFail(manage.DLStageBreaker, errBreakerOpen, destIP, item)
+		}
+		return
+	}
+
+	p, _, err := w.redisPoolMap.FetchOrNew(destIP, w.mgr.Conf.PoolSize)
 
 	if err != nil {
+		w.mgr.Breakers.Failure(destIP)
 		w.Log.Error("fetch "+destIP+" pool fail", zap.Error(err))
+		for _, item := range items {
+			w.pushFail(manage.DLStagePush, err, destIP, item)
+		}
 		return
 	}
 
-	res := pool.Cmd("rpush", w.mgr.Inbox(boxName), bts)
+	client, err := p.Get()
+	if err != nil {
+		w.mgr.Breakers.Failure(destIP)
+		w.Log.Error("get "+destIP+" redis client fail", zap.Error(err))
+		for _, item := range items {
+			w.pushFail(manage.DLStagePush, err, destIP, item)
+		}
+		return
+	}
+	defer p.Put(client)
 
-	if res.Err != nil {
-		w.Log.Error("redis rpush fail", zap.Error(res.Err), msgPackField(msg))
+	for _, item := range items {
+		client.PipeAppend("rpush", item.key, item.bts)
+	}
+
+	ioErr := false
+	for _, item := range items {
+		if res := client.PipeResp(); res.Err != nil {
+			ioErr = ioErr || res.IsType(redis.IOErr)
+			w.Log.Error("redis rpush fail", zap.Error(res.Err), msgPackField(item.msg))
+			w.pushFail(manage.DLStagePush, res.Err, destIP, item)
+		}
+	}
+
+	// 仅链接错误计入熔断
+	if ioErr {
+		w.mgr.Breakers.Failure(destIP)
+	} else {
+		w.mgr.Breakers.Success(destIP)
+	}
+}
+
+// pushFail 推送失败，存入死信队列以便重新投递，并回调
+// 请求由回调应答不可用，不再存入死信队列，避免重新投递后调用者已收到错误，服务的应答却被丢弃
+func (w *CarryWorker) pushFail(stage string, err error, destIP string, item *pushItem) {
+	if item.msg.Action == manage.ActReq && item.onFail != nil {
+		item.onFail(err)
+		return
+	}
+
+	dl := manage.NewDeadLetter(stage, err, item.msg, item.bts)
+	dl.DestIP, dl.Key = destIP, item.key
+	w.deadLetter(dl)
+
+	if item.onFail != nil {
+		item.onFail(err)
 	}
 }
@@ -3,11 +3,14 @@ package work
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/chashu-code/micro-broker/defaults"
 	"github.com/chashu-code/micro-broker/manage"
 	"github.com/chashu-code/micro-broker/pool"
+	"github.com/chashu-code/micro-broker/protocol"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,6 +19,7 @@ func newCarryWorker() *CarryWorker {
 	w.mgr = newManager()
 	w.redisPoolMap = pool.NewRedisPoolMap()
 	w.beanPoolMap = pool.NewBeanPoolMap()
+	w.mgr.RedisPoolMap = w.redisPoolMap
 	return w
 }
 
@@ -25,21 +29,56 @@ func Test_CarryWorker_pushMsg(t *testing.T) {
 	sink := w.newSinkLog()
 	destIP := defaults.IPLocal
 	boxName := w.mgr.Inbox(destIP)
-	w.pushMsg(destIP, boxName, msg)
+	w.pushMsg(destIP, boxName, msg, nil)
 
 	logHas(t, sink, "pack msg fail")
 	msg.V = 1
 
 	errDestIP := "127.0.0.1:6366"
 	sink = w.newSinkLog()
-	w.pushMsg(errDestIP, boxName, msg)
+	w.pushMsg(errDestIP, boxName, msg, nil)
 	logHas(t, sink, "pool fail")
 
 	sink = w.newSinkLog()
-	w.pushMsg(destIP, boxName, msg)
+	w.pushMsg(destIP, boxName, msg, nil)
 	assert.Empty(t, sink.Logs())
 }
 
+func Test_CarryWorker_pushMsgDeadLetter(t *testing.T) {
+	w := newCarryWorker()
+	w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	p.Cmd("del", w.mgr.DLQName())
+
+	// pack fail
+	msg := &manage.Msg{TID: "t-dlq"}
+	w.pushMsg(defaults.IPLocal, "test", msg, nil)
+	dls, _ := w.mgr.DLQ.List(0, -1)
+	assert.Equal(t, 1, len(dls))
+	assert.Equal(t, manage.DLStagePack, dls[0].Stage)
+	assert.Equal(t, "t-dlq", dls[0].TID)
+	assert.Empty(t, dls[0].Raw)
+
+	// push fail, 可重新投递
+	msg.V = 1
+	errDestIP := "127.0.0.1:6366"
+	w.pushMsg(errDestIP, "test", msg, nil)
+	dls, _ = w.mgr.DLQ.List(0, 0)
+	assert.Equal(t, manage.DLStagePush, dls[0].Stage)
+	assert.Equal(t, errDestIP, dls[0].DestIP)
+	assert.Equal(t, w.mgr.Inbox("test"), dls[0].Key)
+	assert.NotEmpty(t, dls[0].Raw)
+
+	// res 无 pid
+	msg.Action = manage.ActRes
+	w.processRes("res <<---", msg)
+	dls, _ = w.mgr.DLQ.List(0, 0)
+	assert.Equal(t, manage.DLStagePid, dls[0].Stage)
+	assert.Empty(t, dls[0].Key)
+
+	p.Cmd("del", w.mgr.DLQName())
+}
+
 func Test_CarrayWorker_processWrongMsgQ(t *testing.T) {
 	w := newCarryWorker()
 	sink := w.newSinkLog()
@@ -56,6 +95,29 @@ func Test_CarrayWorker_processWrongMsgQ(t *testing.T) {
 	logHas(t, sink, "can't carry")
 }
 
+func Test_CarrayWorker_processAck(t *testing.T) {
+	w := newCarryWorker()
+	w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	processing := w.mgr.Processing(defaults.IPLocal)
+	p.Cmd("del", processing)
+	p.Cmd("rpush", processing, "raw")
+
+	msg := &manage.Msg{
+		Action: "unknow",
+		V:      1,
+		Ack: &manage.MsgAck{
+			IP:  defaults.IPLocal,
+			Key: processing,
+			Raw: []byte("raw"),
+		},
+	}
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+	v, _ := p.Cmd("llen", processing).Int()
+	assert.Equal(t, 0, v)
+}
+
 func Test_CarrayWorker_processREQ(t *testing.T) {
 	w := newCarryWorker()
 	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
@@ -76,6 +138,86 @@ func Test_CarrayWorker_processREQ(t *testing.T) {
 	p.Cmd("del", lstName)
 }
 
+func Test_CarrayWorker_processREQPushFail(t *testing.T) {
+	w := newCarryWorker()
+	w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	inbox := w.mgr.Inbox("test-wrong")
+	resBox := w.mgr.Inbox("0")
+	p.Cmd("del", resBox, w.mgr.DLQName())
+	// inbox 类型错误，rpush 失败
+	p.Cmd("set", inbox, "x")
+
+	msg := &manage.Msg{Action: manage.ActReq, Topic: "test-wrong", RID: "0|fail", DeadLine: manage.NowMs() + 60000, V: 1}
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+
+	// 应答调用者不可用，清理等待记录，不存入死信队列
+	assert.Equal(t, 0, w.mgr.Pending.Len())
+	bts, _ := p.Cmd("lpop", resBox).Bytes()
+	msgRes, err := w.mgr.Unpack(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, manage.CodeUnavailable, msgRes.Code)
+	n, _ := w.mgr.DLQ.Len()
+	assert.Equal(t, 0, n)
+
+	p.Cmd("del", inbox, resBox, w.mgr.DLQName())
+}
+
+func Test_CarrayWorker_processREQRoute(t *testing.T) {
+	w := newCarryWorker()
+	w.mgr.SubWrkRun = func(mgr *manage.Manager, ip string, count int) {}
+
+	destIP := "127.0.0.1"
+	w.mgr.Router.Update("1", map[string]string{"test/route": destIP + "|least"})
+
+	p, _, _ := w.redisPoolMap.FetchOrNew(destIP, 1)
+	msg := &manage.Msg{
+		Action:  manage.ActReq,
+		RID:     "0|route",
+		Topic:   "test",
+		Channel: "route",
+		V:       1,
+	}
+	lstName := w.mgr.Inbox(msg.Topic)
+	p.Cmd("del", lstName)
+
+	sink := w.newSinkLog()
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+	logHas(t, sink, "req --->>")
+	logNotHas(t, sink, "redis fail")
+	assert.Equal(t, w.mgr.IP(), msg.BID)
+	assert.Equal(t, 1, w.mgr.Pending.Len())
+	v, _ := p.Cmd("llen", lstName).Int()
+	assert.Equal(t, 1, v)
+	p.Cmd("del", lstName)
+
+	// 应答返回，完成等待
+	w.mgr.MsgQ.Push(msg.Clone(manage.ActRes), false)
+	w.process()
+	assert.Equal(t, 0, w.mgr.Pending.Len())
+	p.Cmd("del", w.mgr.Inbox("0"))
+
+	// 路由 ip 无法链接，应答不可用，不存入死信队列
+	p.Cmd("del", w.mgr.DLQName())
+	w.mgr.Router.Update("2", map[string]string{"test": "127.0.0.1:6366"})
+	sink = w.newSinkLog()
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+	logHas(t, sink, "redis fail", "err res <<---")
+	assert.Equal(t, 0, w.mgr.Pending.Len())
+
+	bts, _ := p.Cmd("lpop", w.mgr.Inbox("0")).Bytes()
+	msgRes, err := w.mgr.Unpack(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, manage.CodeUnavailable, msgRes.Code)
+
+	n, _ := w.mgr.DLQ.Len()
+	assert.Equal(t, 0, n)
+	p.Cmd("del", w.mgr.DLQName())
+}
+
 func Test_CarrayWorker_processRES(t *testing.T) {
 	w := newCarryWorker()
 	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
@@ -101,6 +243,205 @@ func Test_CarrayWorker_processRES(t *testing.T) {
 	p.Cmd("del", lstName)
 }
 
+func Test_CarrayWorker_processRESVersion(t *testing.T) {
+	w := newCarryWorker()
+	w.mgr.AddProtocolGenFn(protocol.VersionHeaders, protocol.NewV2Protocol)
+	sink := w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	resBox := w.mgr.Inbox("0")
+	inbox := w.mgr.Inbox("test")
+	p.Cmd("del", resBox, inbox, w.mgr.RegistryName())
+
+	popVersion := func(key string) byte {
+		bts, _ := p.Cmd("lpop", key).Bytes()
+		assert.NotEmpty(t, bts)
+		return bts[0]
+	}
+
+	// V1 调用者，V2 服务：以调用者请求的 V1 应答
+	req := &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|1234", TID: "tid-v2", DeadLine: manage.NowMs() + 60000, V: 1}
+	w.mgr.MsgQ.Push(req, false)
+	w.process()
+	assert.Equal(t, byte(1), popVersion(inbox))
+
+	res := req.Clone(manage.ActRes)
+	res.V = protocol.VersionHeaders
+	res.Headers = map[string]string{manage.HeaderAuth: "token"}
+	w.mgr.MsgQ.Push(res, false)
+	w.process()
+	logNotHas(t, sink, "token")
+	assert.Equal(t, byte(1), popVersion(resBox))
+
+	// 服务版本取自注册：所有实例均为 V2，则以 V2 投递
+	regV2, _ := manage.NewRegEntry(&manage.Msg{Topic: "test", Channel: "a", BID: "10.0.0.1", RID: "8|a", V: protocol.VersionHeaders}, 10)
+	assert.Nil(t, w.mgr.Registry.Register(regV2))
+	req = &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|5678", DeadLine: manage.NowMs() + 60000, V: 1}
+	w.mgr.MsgQ.Push(req, false)
+	w.process()
+	assert.Equal(t, byte(protocol.VersionHeaders), popVersion(inbox))
+	assert.Equal(t, 1, w.mgr.Counter.Get("translate.1-2"))
+
+	// 存在 V1 实例，则以最低的 V1 投递，丢失的字段计数
+	regV1, _ := manage.NewRegEntry(&manage.Msg{Topic: "test", BID: "10.0.0.2", RID: "9|a", V: 1}, 10)
+	assert.Nil(t, w.mgr.Registry.Register(regV1))
+	req = &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|5679", DeadLine: manage.NowMs() + 60000, V: protocol.VersionHeaders}
+	req.Headers = map[string]string{"baggage-uid": "7"}
+	w.mgr.MsgQ.Push(req, false)
+	w.process()
+	assert.Equal(t, byte(1), popVersion(inbox))
+	assert.Equal(t, 1, w.mgr.Counter.Get("translate.lossy.2-1"))
+	logHas(t, sink, "translate drops fields", "headers")
+
+	// 每对版本仅检测一次，未携带丢失字段的消息不计入
+	sink = w.newSinkLog()
+	req = &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|5680", DeadLine: manage.NowMs() + 60000, V: protocol.VersionHeaders}
+	w.mgr.MsgQ.Push(req, false)
+	w.process()
+	assert.Equal(t, byte(1), popVersion(inbox))
+	assert.Equal(t, 2, w.mgr.Counter.Get("translate.2-1"))
+	assert.Equal(t, 1, w.mgr.Counter.Get("translate.lossy.2-1"))
+	logNotHas(t, sink, "translate drops fields")
+
+	// 压缩协议内容为 V1，转换时丢失的字段同样计数
+	w.mgr.AddProtocolGenFn(protocol.VersionZip, protocol.NewZipProtocol)
+	w.mgr.Versions.Update("1", map[string]string{"test": "3"})
+	req = &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|5681", DeadLine: manage.NowMs() + 60000, V: protocol.VersionHeaders}
+	req.Headers = map[string]string{"baggage-uid": "7"}
+	w.mgr.MsgQ.Push(req, false)
+	w.process()
+	assert.Equal(t, byte(protocol.VersionZip), popVersion(inbox))
+	assert.Equal(t, 1, w.mgr.Counter.Get("translate.lossy.2-3"))
+	logHas(t, sink, "translate drops fields", "2-3", "headers")
+
+	// 配置优先，未支持的版本不转换
+	w.mgr.Versions.Update("1", map[string]string{"test": "9"})
+	w.mgr.MsgQ.Push(req.Clone(manage.ActReq), false)
+	w.process()
+	assert.Equal(t, byte(protocol.VersionHeaders), popVersion(inbox))
+
+	// 无对应请求的应答，调用者版本未配置则不转换
+	res = &manage.Msg{Action: manage.ActRes, Topic: "test", RID: "0|9999", V: protocol.VersionHeaders}
+	w.processRes("res <<---", res)
+	assert.Equal(t, byte(protocol.VersionHeaders), popVersion(resBox))
+
+	p.Cmd("del", resBox, inbox, w.mgr.RegistryName())
+}
+
+func Test_CarrayWorker_processRESReplyTo(t *testing.T) {
+	w := newCarryWorker()
+	w.mgr.AddProtocolGenFn(protocol.VersionHeaders, protocol.NewV2Protocol)
+	w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	resBox := w.mgr.Inbox("0")
+	replyBox := w.mgr.Inbox("reply")
+	p.Cmd("del", resBox, replyBox, w.mgr.Inbox("test"))
+
+	req := &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|1234", DeadLine: manage.NowMs() + 60000, V: protocol.VersionHeaders}
+	req.Headers = map[string]string{manage.HeaderReplyTo: "reply"}
+	w.mgr.MsgQ.Push(req, false)
+	w.process()
+
+	// 以请求的 reply-to 为准，忽略服务应答中的
+	res := &manage.Msg{Action: manage.ActRes, RID: "0|1234", V: protocol.VersionHeaders}
+	res.Headers = map[string]string{manage.HeaderReplyTo: "other"}
+	w.mgr.MsgQ.Push(res, false)
+	w.process()
+	v, _ := p.Cmd("llen", replyBox).Int()
+	assert.Equal(t, 1, v)
+	v, _ = p.Cmd("llen", resBox).Int()
+	assert.Equal(t, 0, v)
+
+	// broker 直接应答的错误（ 无等待记录 ），同样投递至 reply-to
+	req = req.Clone(manage.ActReq)
+	req.RID = "0|5678"
+	w.replyErr(req, manage.CodeBadRequest, "bad")
+	v, _ = p.Cmd("llen", replyBox).Int()
+	assert.Equal(t, 2, v)
+
+	p.Cmd("del", resBox, replyBox, w.mgr.Inbox("test"), w.mgr.Inbox("other"))
+}
+
+func Test_CarrayWorker_processRESLate(t *testing.T) {
+	w := newCarryWorker()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	msg := &manage.Msg{
+		Action:   manage.ActReq,
+		RID:      "0|1234",
+		TID:      "tid-late",
+		DeadLine: manage.NowMs() + 60000,
+		V:        1,
+	}
+	lstName := w.mgr.Inbox("0")
+	p.Cmd("del", lstName)
+
+	// 重复应答
+	w.mgr.Pending.Add(msg, nil)
+	sink := w.newSinkLog()
+	for i := 0; i < 2; i++ {
+		w.mgr.MsgQ.Push(msg.Clone(manage.ActRes), false)
+		w.process()
+	}
+	logHas(t, sink, "duplicate res, drop")
+	assert.Equal(t, 1, w.mgr.Counter.Get("res.duplicate"))
+	v, _ := p.Cmd("llen", lstName).Int()
+	assert.Equal(t, 1, v)
+
+	// 迟到应答（ DeadLine 已过 ），经 SubWorker 获取，不因过期被丢弃
+	sub := &SubWorker{subIP: adjustSubIP(w.mgr.IP(), w.mgr.IP()), destIP: w.mgr.IP()}
+	sub.mgr, sub.redisPoolMap, sub.Log = w.mgr, w.redisPoolMap, w.Log
+	sub.mgr.Conf.PopTimeoutSecs = 1
+	outbox := w.mgr.Outbox(sub.subIP)
+	p.Cmd("del", outbox)
+
+	msg.TID = "tid-late-2"
+	msg.DeadLine = manage.NowMs() - 1
+	w.mgr.Pending.Add(msg, nil)
+	w.mgr.Pending.Expire(manage.NowMs())
+	bts, _ := w.mgr.Pack(msg.Clone(manage.ActRes))
+	p.Cmd("rpush", outbox, bts)
+	sub.process()
+	logNotHas(t, sink, "msg is dead")
+	w.process()
+	logHas(t, sink, "late res, drop")
+	assert.Equal(t, 1, w.mgr.Counter.Get("res.late"))
+	v, _ = p.Cmd("llen", lstName).Int()
+	assert.Equal(t, 1, v)
+	p.Cmd("del", lstName)
+}
+
+func Test_CarrayWorker_processRESRemote(t *testing.T) {
+	w := newCarryWorker()
+	bid := "127.0.0.1"
+	p, _, _ := w.redisPoolMap.FetchOrNew(bid, 1)
+	msg := &manage.Msg{
+		Action: manage.ActRes,
+		BID:    bid,
+		RID:    "0|1234",
+		TID:    "tid-remote",
+		V:      1,
+	}
+
+	// 交回发起方 broker 的 outbox
+	lstName := w.mgr.Outbox(defaults.IPLocal)
+	p.Cmd("del", lstName)
+	sink := w.newSinkLog()
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+	logHas(t, sink, "res <<---")
+	logNotHas(t, sink, "fail")
+	v, _ := p.Cmd("llen", lstName).Int()
+	assert.Equal(t, 1, v)
+	p.Cmd("del", lstName)
+
+	// 发起方 broker 无法链接
+	msg.BID = "127.0.0.1:6366"
+	sink = w.newSinkLog()
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+	logHas(t, sink, "return res to 127.0.0.1:6366 fail", "tid-remote")
+	assert.Equal(t, 1, w.mgr.Counter.Get("res.unreachable"))
+}
+
 func Test_CarrayWorker_processJOB(t *testing.T) {
 	w := newCarryWorker()
 	p, _, _ := w.beanPoolMap.FetchOrNew(defaults.IPLocal, defaults.DefaultJobPoolSize)
@@ -135,3 +476,470 @@ func Test_CarrayWorker_processJOB(t *testing.T) {
 	countNew, _ := strconv.Atoi(mInfo["current-jobs-ready"])
 	assert.Equal(t, countOld+1, countNew)
 }
+
+func Test_CarrayWorker_processJobCtl(t *testing.T) {
+	w := newCarryWorker()
+	sink := w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	resBox := w.mgr.Inbox("0")
+	p.Cmd("del", resBox)
+
+	popRes := func() *manage.Msg {
+		bts, _ := p.Cmd("lpop", resBox).Bytes()
+		msgRes, err := w.mgr.Unpack(bts)
+		assert.Nil(t, err)
+		return msgRes
+	}
+	ctl := func(action string, data interface{}) *manage.Msg {
+		w.mgr.MsgQ.Push(&manage.Msg{Action: action, RID: "0|ctl", Data: data, V: 1}, false)
+		w.process()
+		return popRes()
+	}
+
+	// 投递成功，应答 job id 及 tube
+	job := &manage.Msg{Action: manage.ActJob, Topic: "test", RID: "0|job", Code: "0|60|60", Data: "hello", V: 1}
+	w.mgr.MsgQ.Push(job, false)
+	w.process()
+	msgRes := popRes()
+	assert.Equal(t, "0", msgRes.Code)
+	data := msgRes.Data.(map[string]interface{})
+	assert.Equal(t, job.TubeName(), data["tube"])
+	id, err := (&manage.Msg{Data: data["id"]}).JobID()
+	assert.Nil(t, err)
+
+	msgRes = ctl(manage.ActJobPeek, id)
+	logHas(t, sink, "job.peek --->>", "job.peek res <<---")
+	data = msgRes.Data.(map[string]interface{})
+	assert.Equal(t, "hello", data["data"])
+	assert.Equal(t, job.TubeName(), data["tube"])
+
+	msgRes = ctl(manage.ActJobStats, strconv.FormatUint(id, 10))
+	assert.Equal(t, "delayed", msgRes.Data.(map[string]interface{})["state"])
+
+	msgRes = ctl(manage.ActJobKick, id)
+	assert.Equal(t, "ok", msgRes.Data)
+	msgRes = ctl(manage.ActJobStats, id)
+	assert.Equal(t, "ready", msgRes.Data.(map[string]interface{})["state"])
+
+	// 搁置 ready 的 Job
+	msgRes = ctl(manage.ActJobBury, id)
+	assert.Equal(t, "ok", msgRes.Data)
+	msgRes = ctl(manage.ActJobStats, id)
+	assert.Equal(t, "buried", msgRes.Data.(map[string]interface{})["state"])
+
+	assert.Equal(t, "ok", ctl(manage.ActJobDelete, id).Data)
+	assert.Equal(t, manage.CodeNotFound, ctl(manage.ActJobDelete, id).Code)
+	assert.Equal(t, manage.CodeNotFound, ctl(manage.ActJobPeek, id).Code)
+
+	// 错误的 id
+	assert.Equal(t, manage.CodeBadRequest, ctl(manage.ActJobPeek, "x").Code)
+	logHas(t, sink, "job.peek with error id")
+}
+
+func Test_CarrayWorker_processJobOpts(t *testing.T) {
+	w := newCarryWorker()
+	sink := w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	resBox := w.mgr.Inbox("0")
+	tube := "test-opts"
+	p.Cmd("del", resBox, w.mgr.JobUniqueName(tube, "u1"), w.mgr.JobUniqueName(tube, "u2"), w.mgr.JobUniqueName(tube, "u3"))
+
+	putData := func(opts *manage.JobOpts, data string) *manage.Msg {
+		w.mgr.MsgQ.Push(&manage.Msg{Action: manage.ActJob, Topic: "test", RID: "0|opts", Job: opts, Data: data, V: 1}, false)
+		w.process()
+		bts, _ := p.Cmd("lpop", resBox).Bytes()
+		msgRes, err := w.mgr.Unpack(bts)
+		assert.Nil(t, err)
+		return msgRes
+	}
+	put := func(opts *manage.JobOpts) *manage.Msg {
+		return putData(opts, "hello")
+	}
+
+	// 选项有误，应答 400
+	ttr := int64(0)
+	msgRes := put(&manage.JobOpts{TTR: &ttr})
+	assert.Equal(t, manage.CodeBadRequest, msgRes.Code)
+	logHas(t, sink, "put job with error opts")
+
+	// 指定 tube 及唯一键，重复 Put 应答之前的 id
+	msgRes = put(&manage.JobOpts{Tube: tube, Unique: "u1"})
+	assert.Equal(t, "0", msgRes.Code)
+	data := msgRes.Data.(map[string]interface{})
+	assert.Equal(t, tube, data["tube"])
+	id, err := (&manage.Msg{Data: data["id"]}).JobID()
+	assert.Nil(t, err)
+
+	msgRes = put(&manage.JobOpts{Tube: tube, Unique: "u1"})
+	data = msgRes.Data.(map[string]interface{})
+	assert.Equal(t, true, data["dup"])
+	idDup, _ := (&manage.Msg{Data: data["id"]}).JobID()
+	assert.Equal(t, id, idDup)
+	assert.Equal(t, 1, w.mgr.Counter.Get("job.dup"))
+
+	// 相同唯一键的 Job 正在 Put，应答 409 以便重试
+	p.Cmd("set", w.mgr.JobUniqueName(tube, "u2"), 0, "EX", 60)
+	msgRes = put(&manage.JobOpts{Tube: tube, Unique: "u2"})
+	assert.Equal(t, manage.CodeConflict, msgRes.Code)
+	p.Cmd("del", w.mgr.JobUniqueName(tube, "u2"))
+
+	// Put 失败（ 超出 beanstalk job 大小限制 ），释放唯一键，可重新 Put
+	msgRes = putData(&manage.JobOpts{Tube: tube, Unique: "u3"}, strings.Repeat("x", 70000))
+	assert.Equal(t, "500", msgRes.Code)
+	v, _ := p.Cmd("exists", w.mgr.JobUniqueName(tube, "u3")).Int()
+	assert.Equal(t, 0, v)
+	msgRes = put(&manage.JobOpts{Tube: tube, Unique: "u3"})
+	data = msgRes.Data.(map[string]interface{})
+	assert.Nil(t, data["dup"])
+	idU3, _ := (&manage.Msg{Data: data["id"]}).JobID()
+
+	c := pool.NewBeanClient("127.0.0.1:11300")
+	defer c.Close()
+	c.Delete(id)
+	c.Delete(idU3)
+	p.Cmd("del", w.mgr.JobUniqueName(tube, "u1"), w.mgr.JobUniqueName(tube, "u3"))
+}
+
+func Test_CarrayWorker_processBatch(t *testing.T) {
+	w := newCarryWorker()
+	sink := w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	lstName := w.mgr.Inbox("test")
+	p.Cmd("del", lstName)
+
+	for i := 0; i < 3; i++ {
+		w.mgr.MsgQ.Push(&manage.Msg{Action: manage.ActReq, Topic: "test", V: 1}, false)
+	}
+	// 打包失败的消息不影响同批次其它消息
+	w.mgr.MsgQ.Push(&manage.Msg{Action: manage.ActReq, Topic: "test"}, false)
+
+	w.process()
+	logHas(t, sink, "pack msg fail")
+	assert.Nil(t, w.pushes)
+	v, _ := p.Cmd("llen", lstName).Int()
+	assert.Equal(t, 3, v)
+	assert.Equal(t, 0, w.mgr.MsgQ.Len())
+
+	// 批量推送失败，逐个回调
+	sink = w.newSinkLog()
+	w.pushes = make(map[string][]*pushItem)
+	fails := 0
+	onFail := func(err error) { fails++ }
+	w.pushMsg("127.0.0.1:6366", "test", &manage.Msg{V: 1}, onFail)
+	w.pushMsg("127.0.0.1:6366", "test", &manage.Msg{V: 1}, onFail)
+	assert.Equal(t, 0, fails)
+	w.flush()
+	assert.Equal(t, 2, fails)
+	logHas(t, sink, "pool fail")
+	p.Cmd("del", lstName)
+}
+
+func benchmarkCarryWorkerProcess(b *testing.B, batchSize int) {
+	w := newCarryWorker()
+	w.newSinkLog()
+	w.mgr.Conf.CarryBatchSize = batchSize
+	w.mgr.MsgQ = manage.NewMsgQueueWithSize(1, b.N)
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	lstName := w.mgr.Inbox("bench")
+	p.Cmd("del", lstName)
+
+	for i := 0; i < b.N; i++ {
+		w.mgr.MsgQ.Push(&manage.Msg{Action: manage.ActReq, Topic: "bench", V: 1}, false)
+	}
+
+	b.ResetTimer()
+	for w.mgr.MsgQ.Len() > 0 {
+		w.process()
+	}
+	b.StopTimer()
+	p.Cmd("del", lstName)
+}
+
+func Benchmark_CarryWorker_processSingle(b *testing.B) {
+	benchmarkCarryWorkerProcess(b, 1)
+}
+
+func Benchmark_CarryWorker_processBatch(b *testing.B) {
+	benchmarkCarryWorkerProcess(b, 10)
+}
+
+func Test_CarrayWorker_breaker(t *testing.T) {
+	w := newCarryWorker()
+	w.mgr.Breakers = manage.NewBreakers(1, 60)
+	sink := w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	p.Cmd("del", w.mgr.DLQName())
+	lstName := w.mgr.Inbox("0")
+	p.Cmd("del", lstName)
+
+	errDestIP := "127.0.0.1:6366"
+	w.mgr.Router.Update("1", map[string]string{"test": errDestIP})
+	msg := &manage.Msg{
+		Action:   manage.ActReq,
+		Topic:    "test",
+		RID:      "0|1234",
+		DeadLine: manage.NowMs() + 60000,
+		V:        1,
+	}
+
+	// 首次失败后熔断
+	w.mgr.Breakers.Failure(errDestIP)
+	assert.True(t, w.mgr.Breakers.IsOpen(errDestIP))
+
+	// 请求直接应答不可用
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+	logHas(t, sink, "unavailable res <<---")
+	logNotHas(t, sink, "connect "+errDestIP)
+	assert.Equal(t, 0, w.mgr.Pending.Len())
+	bts, _ := p.Cmd("lpop", lstName).Bytes()
+	msgRes, err := w.mgr.Unpack(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, manage.CodeUnavailable, msgRes.Code)
+
+	// 其余消息存入死信队列
+	w.pushMsg(errDestIP, "test", &manage.Msg{Action: manage.ActRes, V: 1}, nil)
+	dls, _ := w.mgr.DLQ.List(0, -1)
+	assert.Equal(t, 1, len(dls))
+	assert.Equal(t, manage.DLStageBreaker, dls[0].Stage)
+	assert.Equal(t, errDestIP, dls[0].DestIP)
+	assert.Equal(t, 1, w.mgr.Counter.Get("breaker.reject"))
+	p.Cmd("del", w.mgr.DLQName())
+}
+
+func Test_CarrayWorker_processREQLimit(t *testing.T) {
+	w := newCarryWorker()
+	sink := w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	inbox := w.mgr.Inbox("test")
+	resBox := w.mgr.Inbox("0")
+	p.Cmd("del", inbox, resBox)
+
+	w.mgr.Limiter.Update("1", map[string]string{"test": "1"})
+	msg := &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|1234", V: 1}
+
+	for i := 0; i < 2; i++ {
+		w.mgr.MsgQ.Push(msg.Clone(manage.ActReq), false)
+		w.process()
+	}
+
+	// 超限请求直接应答，不再投递
+	logHas(t, sink, "limit res <<---")
+	assert.Equal(t, 1, w.mgr.Counter.Get("limit.test"))
+	v, _ := p.Cmd("llen", inbox).Int()
+	assert.Equal(t, 1, v)
+	bts, _ := p.Cmd("lpop", resBox).Bytes()
+	msgRes, err := w.mgr.Unpack(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, manage.CodeTooMany, msgRes.Code)
+	p.Cmd("del", inbox, resBox)
+}
+
+func Test_CarrayWorker_processDuplicate(t *testing.T) {
+	w := newCarryWorker()
+	w.mgr.Dedup = manage.NewDedup(10)
+	sink := w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	inbox := w.mgr.Inbox("test")
+	p.Cmd("del", inbox)
+
+	msg := &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|1234", TID: "tid-dup", V: 1}
+	for i := 0; i < 2; i++ {
+		w.mgr.MsgQ.Push(msg.Clone(manage.ActReq), false)
+		w.process()
+	}
+
+	logHas(t, sink, "duplicate msg, drop")
+	assert.Equal(t, 1, w.mgr.Counter.Get("dedup.req"))
+	v, _ := p.Cmd("llen", inbox).Int()
+	assert.Equal(t, 1, v)
+	p.Cmd("del", inbox)
+}
+
+func Test_CarrayWorker_processDelay(t *testing.T) {
+	w := newCarryWorker()
+	w.mgr.AddProtocolGenFn(protocol.VersionHeaders, protocol.NewV2Protocol)
+	w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	inbox := w.mgr.Inbox("test")
+	name := w.mgr.DelayName()
+	p.Cmd("del", inbox, name)
+
+	now := time.Now().Unix()
+	msg := &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|delay", DeadLine: (now + 60) * 1000, DeliverAt: now + 30, V: protocol.VersionHeaders}
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+
+	// 未到投递时间，存入延迟集合
+	v, _ := p.Cmd("llen", inbox).Int()
+	assert.Equal(t, 0, v)
+	v, _ = p.Cmd("zcount", name, now+30, now+30).Int()
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 1, w.mgr.Counter.Get("delay.test"))
+
+	// 已到投递时间，直接投递
+	msg = &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|due", DeadLine: (now + 60) * 1000, DeliverAt: now, V: protocol.VersionHeaders}
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+	v, _ = p.Cmd("llen", inbox).Int()
+	assert.Equal(t, 1, v)
+
+	p.Cmd("del", inbox, name)
+}
+
+func Test_CarrayWorker_processClaim(t *testing.T) {
+	w := newCarryWorker()
+	w.mgr.Conf.ClaimMinSize = 50
+	w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	inbox := w.mgr.Inbox("test")
+	resBox := w.mgr.Inbox("0")
+	p.Cmd("del", inbox, resBox)
+
+	// 请求始终投递原数据
+	data := strings.Repeat("x", 100)
+	msg := &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|1234", Data: data, V: 1}
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+	assert.Equal(t, 0, w.mgr.Counter.Get("claim.req"))
+	bts, _ := p.Cmd("lpop", inbox).Bytes()
+	msgIn, err := w.mgr.Unpack(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, data, msgIn.Data)
+
+	// Job 仅保留凭证，以凭证取回
+	job := &manage.Msg{Action: manage.ActJob, Topic: "test", RID: "0|claim", TID: "tid-claim", Data: data, V: 1}
+	w.mgr.MsgQ.Push(job, false)
+	w.process()
+	assert.Equal(t, 1, w.mgr.Counter.Get("claim.job"))
+	bts, _ = p.Cmd("lpop", resBox).Bytes()
+	msgRes, _ := w.mgr.Unpack(bts)
+	id := msgRes.Data.(map[string]interface{})["id"]
+
+	c := pool.NewBeanClient("127.0.0.1:11300")
+	defer c.Close()
+	jobID, _ := (&manage.Msg{Data: id}).JobID()
+	body, err := c.Peek(jobID)
+	assert.Nil(t, err)
+	msgJob, _ := w.mgr.Unpack(body)
+	key := manage.ClaimRef(msgJob.Data)
+	assert.NotEmpty(t, key)
+	w.mgr.Claims.Load(defaults.IPLocal, msgJob)
+	assert.Equal(t, data, msgJob.Data)
+
+	// 删除 Job，一并删除凭证
+	w.mgr.MsgQ.Push(&manage.Msg{Action: manage.ActJobDelete, RID: "0|ctl", Data: id, V: 1}, false)
+	w.process()
+	n, _ := p.Cmd("exists", key).Int()
+	assert.Equal(t, 0, n)
+	p.Cmd("del", resBox)
+}
+
+func Test_CarrayWorker_processBuiltin(t *testing.T) {
+	w := newCarryWorker()
+	sink := w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	resBox := w.mgr.Inbox("0")
+	p.Cmd("del", resBox)
+
+	popRes := func() *manage.Msg {
+		bts, _ := p.Cmd("lpop", resBox).Bytes()
+		msgRes, err := w.mgr.Unpack(bts)
+		assert.Nil(t, err)
+		return msgRes
+	}
+
+	msg := &manage.Msg{Action: manage.ActPing, RID: "0|1234", V: 1}
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+	logHas(t, sink, "ping --->>", "ping res <<---")
+	msgRes := popRes()
+	assert.Equal(t, manage.ActRes, msgRes.Action)
+	assert.Equal(t, "pong", msgRes.Data)
+	assert.Equal(t, "0|1234", msgRes.RID)
+
+	msg = &manage.Msg{Action: manage.ActEcho, RID: "0|1234", Data: "hello", V: 1}
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+	assert.Equal(t, "hello", popRes().Data)
+
+	msg = &manage.Msg{Action: manage.ActInfo, RID: "0|1234", V: 1}
+	w.mgr.MsgQ.Push(msg, false)
+	w.process()
+	info, ok := popRes().Data.(map[string]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, w.mgr.IP(), info["ip"])
+	assert.Equal(t, defaults.Version, info["version"])
+	assert.Contains(t, info, "uptime")
+	assert.Contains(t, info, "msgq")
+	assert.Contains(t, info, "outbox")
+}
+
+func Test_CarrayWorker_processReg(t *testing.T) {
+	w := newCarryWorker()
+	w.mgr.Conf.RegistryStrict = true
+	sink := w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	resBox := w.mgr.Inbox("0")
+	inbox := w.mgr.Inbox("test")
+	p.Cmd("del", resBox, inbox, w.mgr.RegistryName())
+
+	popRes := func() *manage.Msg {
+		bts, _ := p.Cmd("lpop", resBox).Bytes()
+		msgRes, err := w.mgr.Unpack(bts)
+		assert.Nil(t, err)
+		return msgRes
+	}
+
+	// 未注册，拒绝
+	req := &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|1234", V: 1}
+	w.mgr.MsgQ.Push(req.Clone(manage.ActReq), false)
+	w.process()
+	assert.Equal(t, manage.CodeNotFound, popRes().Code)
+
+	// 注册
+	reg := &manage.Msg{Action: manage.ActReg, Topic: "test", RID: "0|1", Data: "1.0", V: 1}
+	w.mgr.MsgQ.Push(reg, false)
+	w.process()
+	logHas(t, sink, "reg --->>", "reg res <<---")
+	assert.Equal(t, "ok", popRes().Data)
+	entries := w.mgr.Registry.List()
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, w.mgr.IP(), entries[0].Host)
+	assert.Equal(t, "1.0", entries[0].Version)
+
+	w.mgr.MsgQ.Push(req.Clone(manage.ActReq), false)
+	w.process()
+	v, _ := p.Cmd("llen", inbox).Int()
+	assert.Equal(t, 1, v)
+
+	// 注销
+	msgUnreg := reg.Clone(manage.ActReq)
+	msgUnreg.Action = manage.ActUnreg
+	w.mgr.MsgQ.Push(msgUnreg, false)
+	w.process()
+	assert.Equal(t, "ok", popRes().Data)
+	assert.Empty(t, w.mgr.Registry.List())
+	p.Cmd("del", resBox, inbox, w.mgr.RegistryName())
+}
+
+func Test_CarrayWorker_registryIP(t *testing.T) {
+	w := newCarryWorker()
+	w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	p.Cmd("del", w.mgr.RegistryName())
+
+	msg := &manage.Msg{Topic: "test", RID: "0|1", BID: "10.0.0.1"}
+	assert.Equal(t, defaults.IPLocal, w.registryIP(msg))
+
+	e, _ := manage.NewRegEntry(msg, 10)
+	w.mgr.Registry.Register(e)
+	assert.Equal(t, "10.0.0.1", w.registryIP(msg))
+
+	// 本机已注册，优先本机
+	msg.BID = w.mgr.IP()
+	e, _ = manage.NewRegEntry(msg, 10)
+	w.mgr.Registry.Register(e)
+	assert.Equal(t, defaults.IPLocal, w.registryIP(msg))
+	p.Cmd("del", w.mgr.RegistryName())
+}
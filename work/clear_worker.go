@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/chashu-code/micro-broker/defaults"
 	"github.com/chashu-code/micro-broker/manage"
 	"github.com/chashu-code/micro-broker/utils"
 	"github.com/uber-go/zap"
@@ -20,6 +21,8 @@ type ClearWorker struct {
 	clearCounter int
 	// redis pool ping
 	pingCounter int
+	// counter statistics
+	statCounter int
 }
 
 // ClearWorkerRun 运行1个 ClearWorkerRun
@@ -37,6 +40,91 @@ func (w *ClearWorker) process() {
 	w.syncLog()
 	w.clearResQueue()
 	w.redisPoolPing()
+	w.logCounter()
+	w.expirePending()
+	w.mgr.Dedup.Expire(time.Now().Unix())
+}
+
+// expirePending 清理已过期的等待应答请求，并应答调用者超时
+func (w *ClearWorker) expirePending() {
+	for _, req := range w.mgr.Pending.Expire(manage.NowMs()) {
+		w.mgr.Router.Done(req.Node)
+		w.replyTimeoutDirect(req.Msg)
+	}
+}
+
+// replyTimeoutDirect 直接投递超时应答（ 不经 MsgQ，避免被视为迟到的应答而丢弃 ）
+func (w *ClearWorker) replyTimeoutDirect(msg *manage.Msg) {
+	msgRes := w.timeoutRes(msg)
+	if msgRes == nil {
+		return
+	}
+
+	pid, err := msgRes.PidOfRID()
+	if err != nil {
+		w.Log.Error("get pid fail", zap.Error(err), msgPackField(msgRes))
+		return
+	}
+
+	// 投递至请求指定的 reply-to inbox；请求来自远端 broker：交回其 outbox
+	box := pid
+	if replyTo := msgRes.Headers[manage.HeaderReplyTo]; replyTo != "" {
+		box = replyTo
+	}
+	destIP, key := defaults.IPLocal, w.mgr.Inbox(box)
+	if !w.mgr.IsLocal(msgRes.BID) {
+		destIP, key = msgRes.BID, w.mgr.Outbox(defaults.IPLocal)
+	}
+
+	bts, err := w.mgr.Pack(msgRes)
+	if err != nil {
+		w.Log.Error("pack msg fail", zap.Error(err), msgPackField(msgRes))
+		return
+	}
+
+	p, _, err := w.redisPoolMap.FetchOrNew(destIP, w.mgr.Conf.PoolSize)
+	if err == nil {
+		err = p.Cmd("rpush", key, bts).Err
+	}
+
+	if err != nil {
+		w.Log.Error("reply timeout fail", zap.Error(err), msgPackField(msgRes))
+		dl := manage.NewDeadLetter(manage.DLStagePush, err, msgRes, bts)
+		dl.DestIP, dl.Key = destIP, key
+		w.deadLetter(dl)
+		return
+	}
+
+	w.Log.Info("timeout res <<---", msgPackField(msgRes))
+}
+
+// logCounter 定时输出计数统计，并清零
+func (w *ClearWorker) logCounter() {
+	// 10s 处理一次
+	w.statCounter = w.statCounter % 10
+
+	if w.statCounter == 0 {
+		var fields []zap.Field
+
+		for name, n := range w.mgr.Counter.Reset() {
+			fields = append(fields, zap.Int(name, n))
+		}
+
+		if len(fields) > 0 {
+			w.Log.Info("counter statistics", fields...)
+		}
+
+		fields = nil
+		for addr, state := range w.mgr.Breakers.States() {
+			fields = append(fields, zap.String(addr, state))
+		}
+
+		if len(fields) > 0 {
+			w.Log.Warn("breaker states", fields...)
+		}
+	}
+
+	w.statCounter++
 }
 
 // redisPoolPing 定时ping redis connection，预防超时
@@ -4,7 +4,9 @@ import (
 	"testing"
 
 	"github.com/chashu-code/micro-broker/defaults"
+	"github.com/chashu-code/micro-broker/manage"
 	"github.com/chashu-code/micro-broker/pool"
+	"github.com/chashu-code/micro-broker/protocol"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -48,3 +50,71 @@ func Test_ClearWorker_clearResQueue(t *testing.T) {
 	p.Cmd("del", w.mgr.Inbox("test"))
 	p.Cmd("del", w.mgr.Inbox("0"))
 }
+
+func Test_ClearWorker_logCounter(t *testing.T) {
+	w := newClearWorker()
+	sink := w.newSinkLog()
+
+	// 无计数，不输出
+	w.logCounter()
+	logNotHas(t, sink, "counter statistics")
+
+	// 10s 内不输出
+	w.mgr.Counter.Incr("test.count")
+	for i := 1; i < 10; i++ {
+		w.logCounter()
+	}
+	logNotHas(t, sink, "counter statistics")
+
+	w.mgr.Breakers.Failures = 1
+	w.mgr.Breakers.Failure("10.0.0.1")
+	w.logCounter()
+	logHas(t, sink, "counter statistics", "test.count", "breaker.open")
+	logHas(t, sink, "breaker states", "10.0.0.1")
+	assert.Equal(t, 0, w.mgr.Counter.Get("test.count"))
+}
+
+func Test_ClearWorker_expirePending(t *testing.T) {
+	w := newClearWorker()
+	w.mgr.Router.Update("1", map[string]string{"test": "10.0.0.1|least"})
+
+	msg := &manage.Msg{Topic: "test", RID: "1|a", DeadLine: manage.NowMs() + 60000}
+	w.mgr.Pending.Add(msg, w.mgr.Router.Route(msg))
+	w.mgr.Pending.Add(&manage.Msg{Action: manage.ActReq, RID: "1|b", V: 1}, nil)
+
+	w.redisPoolMap = pool.NewRedisPoolMap()
+	w.mgr.RedisPoolMap = w.redisPoolMap
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	lstName := w.mgr.Inbox("1")
+	p.Cmd("del", lstName)
+
+	sink := w.newSinkLog()
+	w.expirePending()
+	assert.Equal(t, 1, w.mgr.Pending.Len())
+
+	// 应答调用者超时
+	logHas(t, sink, "timeout res <<---")
+	assert.Equal(t, 1, w.mgr.Counter.Get("timeout."))
+	bts, _ := p.Cmd("lpop", lstName).Bytes()
+	msgRes, err := w.mgr.Unpack(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, manage.ActRes, msgRes.Action)
+	assert.Equal(t, manage.CodeTimeout, msgRes.Code)
+	assert.Equal(t, manage.PendingExpired, w.mgr.Pending.State(msgRes))
+
+	// 投递至请求指定的 reply-to inbox
+	w.mgr.AddProtocolGenFn(protocol.VersionHeaders, protocol.NewV2Protocol)
+	replyBox := w.mgr.Inbox("1-reply")
+	p.Cmd("del", replyBox)
+	w.mgr.Pending.Add(&manage.Msg{Action: manage.ActReq, RID: "1|c", V: protocol.VersionHeaders,
+		Headers: map[string]string{manage.HeaderReplyTo: "1-reply"}}, nil)
+	w.expirePending()
+	bts, _ = p.Cmd("lpop", replyBox).Bytes()
+	msgRes, err = w.mgr.Unpack(bts)
+	assert.Nil(t, err)
+	assert.Equal(t, "1|c", msgRes.RID)
+	assert.Equal(t, manage.CodeTimeout, msgRes.Code)
+	v, _ := p.Cmd("llen", lstName).Int()
+	assert.Equal(t, 0, v)
+	p.Cmd("del", replyBox)
+}
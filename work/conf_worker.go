@@ -17,6 +17,15 @@ type ConfWorker struct {
 	IP string
 	// V Version
 	V string
+	// RouteV route Version
+	RouteV string
+	// LimitV limit Version
+	LimitV string
+	// VersionV protocol version table Version
+	VersionV string
+
+	// regLoadAt 上次同步服务注册表的时间
+	regLoadAt time.Time
 }
 
 // ConfWorkerRun 运行1个 ConfWorkerRun
@@ -40,6 +49,10 @@ func (w *ConfWorker) process() {
 	}
 
 	w.processCrontab(pool)
+	w.processRoute(pool)
+	w.processLimit(pool)
+	w.processVersion(pool)
+	w.processRegistry()
 }
 
 func (w *ConfWorker) processCrontab(pool *rxpool.Pool) {
@@ -77,6 +90,80 @@ func (w *ConfWorker) processCrontab(pool *rxpool.Pool) {
 	}
 }
 
+func (w *ConfWorker) processRoute(pool *rxpool.Pool) {
+	mp, wrongs := w.syncVersioned(pool, w.mgr.RouteName(), "route", &w.RouteV, w.mgr.Router.Update)
+	for _, name := range wrongs {
+		w.Log.Warn("wrong route DSL", zap.String("name", name), zap.String("dsl", mp[name]))
+	}
+}
+
+func (w *ConfWorker) processLimit(pool *rxpool.Pool) {
+	mp, wrongs := w.syncVersioned(pool, w.mgr.LimitName(), "limit", &w.LimitV, w.mgr.Limiter.Update)
+	for _, name := range wrongs {
+		w.Log.Warn("wrong limit DSL", zap.String("name", name), zap.String("dsl", mp[name]))
+	}
+}
+
+func (w *ConfWorker) processVersion(pool *rxpool.Pool) {
+	mp, wrongs := w.syncVersioned(pool, w.mgr.VersionName(), "protocol version", &w.VersionV, w.mgr.Versions.Update)
+	for _, name := range wrongs {
+		w.Log.Warn("wrong protocol version", zap.String("name", name), zap.String("version", mp[name]))
+	}
+}
+
+// syncVersioned 同步带版本（ "v" 字段 ）的配置 hash table，版本变化时记录至 curV 并调用 update（ 无版本则以空配置调用 ），
+// 返回获取到的配置，及 update 返回的配置有误（被忽略）的字段名，由调用方按配置类型记录
+func (w *ConfWorker) syncVersioned(pool *rxpool.Pool, tabName, kind string, curV *string,
+	update func(string, map[string]string) []string) (map[string]string, []string) {
+	res := pool.Cmd("hget", tabName, "v")
+
+	v, err := w.resToV(res)
+
+	if err != nil {
+		w.Log.Warn("get "+kind+" version fail", zap.Error(err))
+		return nil, nil
+	}
+
+	// 若版本信息一致，则不作处理
+	if v == *curV {
+		return nil, nil
+	}
+
+	// 无版本信息，清空配置
+	if v == "" {
+		*curV = v
+		w.Log.Info("no version, clear " + kind)
+		update(v, map[string]string{})
+		return nil, nil
+	}
+
+	// 有版本信息，尝试获取整个hash table，失败则下次重试
+	res = pool.Cmd("hgetall", tabName)
+	mp, err := res.Map()
+	if err != nil {
+		w.Log.Warn("get "+kind+" fail", zap.Error(err))
+		return nil, nil
+	}
+
+	*curV = v
+	wrongs := update(v, mp)
+	w.Log.Info("get "+kind+" success", zap.Object("config", mp))
+	return mp, wrongs
+}
+
+// processRegistry 每隔 RegistrySyncSecs 秒同步服务注册表（ 出错也等待下个间隔 ）
+func (w *ConfWorker) processRegistry() {
+	durSync := time.Duration(w.mgr.Conf.RegistrySyncSecs) * time.Second
+	if !w.regLoadAt.IsZero() && time.Since(w.regLoadAt) < durSync {
+		return
+	}
+
+	w.regLoadAt = time.Now()
+	if _, err := w.mgr.Registry.Load(); err != nil {
+		w.Log.Warn("load registry fail", zap.Error(err))
+	}
+}
+
 func (w *ConfWorker) resToV(res *redis.Resp) (string, error) {
 	// 空，就当清零
 	if res.IsType(redis.Nil) {
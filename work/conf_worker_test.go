@@ -3,8 +3,10 @@ package work
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/chashu-code/micro-broker/defaults"
+	"github.com/chashu-code/micro-broker/manage"
 	"github.com/chashu-code/micro-broker/pool"
 	"github.com/mediocregopher/radix.v2/redis"
 	"github.com/stretchr/testify/assert"
@@ -69,3 +71,136 @@ func Test_ConfWorker_processCrontab(t *testing.T) {
 	assert.Equal(t, "update", w.mgr.Conf.CrontabJobDslMap["v"])
 	assert.Equal(t, "fv1", w.mgr.Conf.CrontabJobDslMap["f1"])
 }
+
+func Test_ConfWorker_processRoute(t *testing.T) {
+	w := newConfWorker()
+	w.redisPoolMap = pool.NewRedisPoolMap()
+
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	tabName := w.mgr.RouteName()
+
+	// route empty
+	p.Cmd("del", tabName)
+	sink := w.newSinkLog()
+	w.processRoute(p)
+	assert.Empty(t, sink.Logs())
+
+	// route error data format
+	p.Cmd("set", tabName, "abc")
+	sink = w.newSinkLog()
+	w.processRoute(p)
+	logHas(t, sink, "get route version fail")
+
+	// 更新
+	p.Cmd("del", tabName)
+	p.Cmd("hmset", tabName, "v", "update", "test", "10.0.0.1", "wrong", "10.0.0.1|unknown")
+	sink = w.newSinkLog()
+	w.processRoute(p)
+	logHas(t, sink, "get route success", "wrong route DSL")
+	assert.Equal(t, "update", w.mgr.Router.V)
+	assert.Equal(t, "10.0.0.1", w.mgr.Router.Route(&manage.Msg{Topic: "test"}).IP)
+
+	// 版本不变，不处理
+	sink = w.newSinkLog()
+	w.processRoute(p)
+	assert.Empty(t, sink.Logs())
+
+	// 清理
+	p.Cmd("del", tabName)
+	sink = w.newSinkLog()
+	w.processRoute(p)
+	logHas(t, sink, "clear route")
+	assert.Nil(t, w.mgr.Router.Route(&manage.Msg{Topic: "test"}))
+}
+
+func Test_ConfWorker_processLimit(t *testing.T) {
+	w := newConfWorker()
+	w.redisPoolMap = pool.NewRedisPoolMap()
+
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	tabName := w.mgr.LimitName()
+	msg := &manage.Msg{Topic: "test"}
+
+	// limit empty
+	p.Cmd("del", tabName)
+	sink := w.newSinkLog()
+	w.processLimit(p)
+	assert.Empty(t, sink.Logs())
+
+	// 更新
+	p.Cmd("hmset", tabName, "v", "update", "test", "1", "wrong", "x")
+	sink = w.newSinkLog()
+	w.processLimit(p)
+	logHas(t, sink, "get limit success", "wrong limit DSL")
+	assert.Equal(t, "update", w.mgr.Limiter.V)
+	w.mgr.Limiter.Allow(msg)
+	name, _ := w.mgr.Limiter.Allow(msg)
+	assert.Equal(t, "test", name)
+
+	// 版本不变，不处理
+	sink = w.newSinkLog()
+	w.processLimit(p)
+	assert.Empty(t, sink.Logs())
+
+	// 清理
+	p.Cmd("del", tabName)
+	sink = w.newSinkLog()
+	w.processLimit(p)
+	logHas(t, sink, "clear limit")
+	name, _ = w.mgr.Limiter.Allow(msg)
+	assert.Equal(t, "", name)
+}
+
+func Test_ConfWorker_processVersion(t *testing.T) {
+	w := newConfWorker()
+	w.redisPoolMap = pool.NewRedisPoolMap()
+
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	tabName := w.mgr.VersionName()
+
+	// empty
+	p.Cmd("del", tabName)
+	sink := w.newSinkLog()
+	w.processVersion(p)
+	assert.Empty(t, sink.Logs())
+
+	// 更新
+	p.Cmd("hmset", tabName, "v", "update", "test", "2", "wrong", "x")
+	sink = w.newSinkLog()
+	w.processVersion(p)
+	logHas(t, sink, "get protocol version success", "wrong protocol version")
+	assert.Equal(t, "update", w.mgr.Versions.V)
+	assert.Equal(t, uint(2), w.mgr.Versions.Get("test"))
+
+	// 版本不变，不处理
+	sink = w.newSinkLog()
+	w.processVersion(p)
+	assert.Empty(t, sink.Logs())
+
+	// 清理
+	p.Cmd("del", tabName)
+	sink = w.newSinkLog()
+	w.processVersion(p)
+	logHas(t, sink, "clear protocol version")
+	assert.Equal(t, uint(0), w.mgr.Versions.Get("test"))
+}
+
+func Test_ConfWorker_processRegistry(t *testing.T) {
+	w := newConfWorker()
+	w.mgr.Conf.RegistrySyncSecs = 2
+	sink := w.newSinkLog()
+
+	// 无法获取 pool
+	w.processRegistry()
+	logHas(t, sink, "load registry fail")
+
+	// 间隔期内不处理
+	sink = w.newSinkLog()
+	w.processRegistry()
+	assert.Empty(t, sink.Logs())
+
+	// 按距上次同步的时间，而非调用次数
+	w.regLoadAt = w.regLoadAt.Add(-2 * time.Second)
+	w.processRegistry()
+	logHas(t, sink, "load registry fail")
+}
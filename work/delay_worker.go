@@ -0,0 +1,90 @@
+package work
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/chashu-code/micro-broker/defaults"
+	"github.com/chashu-code/micro-broker/manage"
+	"github.com/mediocregopher/radix.v2/util"
+	"github.com/uber-go/zap"
+)
+
+// luaPopDue 原子地取出至多 ARGV[2] 个 score <= ARGV[1] 的成员
+const luaPopDue = `
+local msgs = redis.call('zrangebyscore', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #msgs > 0 then
+	redis.call('zrem', KEYS[1], unpack(msgs))
+end
+return msgs
+`
+
+// DelayWorker 延迟请求调度工作器：将到期的请求从 sorted set 移回 MsgQ
+type DelayWorker struct {
+	Worker
+	// IP redis ip
+	IP string
+}
+
+// DelayWorkerRun 运行1个 DelayWorker
+func DelayWorkerRun(mgr *manage.Manager, ip string, count int) {
+	w := &DelayWorker{
+		IP: ip,
+	}
+	go w.Run(mgr, "delay:"+ip, w.process)
+}
+
+func (w *DelayWorker) process() {
+	durPause := time.Duration(w.mgr.Conf.WrkPauseSecs) * time.Second
+
+	// 未取满，则间歇一会
+	if w.popDue() < w.mgr.Conf.DelayBatchSize {
+		time.Sleep(durPause)
+	}
+}
+
+// popDue 取出到期的请求放入 MsgQ，返回取出数量
+func (w *DelayWorker) popDue() int {
+	p, _, err := w.redisPoolMap.FetchOrNew(w.IP, w.mgr.Conf.PoolSize)
+	if err != nil {
+		w.Log.Error("get delay redis pool fail", zap.Error(err))
+		return 0
+	}
+
+	var lstBytes [][]byte
+	name := w.mgr.DelayName()
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	lstBytes, err = util.LuaEval(p, luaPopDue, 1, name, now, w.mgr.Conf.DelayBatchSize).ListBytes()
+	if err != nil {
+		w.Log.Error("pop due msgs fail", zap.Error(err))
+		return 0
+	}
+
+	for _, bts := range lstBytes {
+		msg, err := w.mgr.Unpack(bts)
+		if err != nil {
+			dl := manage.NewDeadLetter(manage.DLStageUnpack, err, nil, bts)
+			w.deadLetter(dl)
+			continue
+		}
+
+		// 已过期，应答超时
+		if msg.IsDead() {
+			w.replyTimeout(msg)
+			continue
+		}
+
+		if !w.mgr.MsgQ.Push(msg, true) {
+			// 放回，下次再处理
+			w.Log.Warn("push msgQ timeout, back to delay", msgPackField(msg))
+			if res := p.Cmd("zadd", name, msg.DeliverAt, bts); res.Err != nil {
+				w.Log.Error("back to delay fail", zap.Error(res.Err), msgPackField(msg))
+				dl := manage.NewDeadLetter(manage.DLStagePush, res.Err, msg, bts)
+				dl.DestIP, dl.Key = defaults.IPLocal, w.mgr.Outbox(defaults.IPLocal)
+				w.deadLetter(dl)
+			}
+		}
+	}
+
+	return len(lstBytes)
+}
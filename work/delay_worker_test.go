@@ -0,0 +1,86 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chashu-code/micro-broker/defaults"
+	"github.com/chashu-code/micro-broker/manage"
+	"github.com/chashu-code/micro-broker/pool"
+	"github.com/chashu-code/micro-broker/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDelayWorker() *DelayWorker {
+	w := &DelayWorker{IP: defaults.IPLocal}
+	w.mgr = newManager()
+	w.mgr.AddProtocolGenFn(protocol.VersionHeaders, protocol.NewV2Protocol)
+	w.redisPoolMap = pool.NewRedisPoolMap()
+	w.mgr.RedisPoolMap = w.redisPoolMap
+	return w
+}
+
+func Test_DelayWorker_popDue(t *testing.T) {
+	w := newDelayWorker()
+	w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	name := w.mgr.DelayName()
+	p.Cmd("del", name, w.mgr.DLQName())
+
+	now := time.Now().Unix()
+	due := &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|due", DeadLine: (now + 60) * 1000, DeliverAt: now, V: protocol.VersionHeaders}
+	later := &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|later", DeadLine: (now + 60) * 1000, DeliverAt: now + 60, V: protocol.VersionHeaders}
+	dead := &manage.Msg{Action: manage.ActReq, Topic: "test", RID: "0|dead", DeadLine: (now - 1) * 1000, DeliverAt: now - 2, V: protocol.VersionHeaders}
+	for _, msg := range []*manage.Msg{due, later, dead} {
+		bts, _ := w.mgr.Pack(msg)
+		p.Cmd("zadd", name, msg.DeliverAt, bts)
+	}
+	p.Cmd("zadd", name, now, []byte{0, 1})
+
+	assert.Equal(t, 3, w.popDue())
+
+	// 未到期的保留
+	v, _ := p.Cmd("zcard", name).Int()
+	assert.Equal(t, 1, v)
+
+	// 已过期的应答超时，到期的放回 MsgQ
+	assert.Equal(t, 2, w.mgr.MsgQ.Len())
+	msgs := w.mgr.MsgQ.PopBatch(2, 0)
+	rids := map[string]*manage.Msg{}
+	for _, msg := range msgs {
+		rids[msg.RID] = msg
+	}
+	assert.Equal(t, manage.ActReq, rids[due.RID].Action)
+	assert.Equal(t, manage.ActRes, rids[dead.RID].Action)
+	assert.Equal(t, manage.CodeTimeout, rids[dead.RID].Code)
+	assert.Equal(t, 1, w.mgr.Counter.Get("timeout.test"))
+
+	// 无法解析，存入死信队列
+	dls, _ := w.mgr.DLQ.List(0, -1)
+	assert.Equal(t, 1, len(dls))
+	assert.Equal(t, manage.DLStageUnpack, dls[0].Stage)
+
+	p.Cmd("del", name, w.mgr.DLQName())
+}
+
+func Test_DelayWorker_popDueQueueFull(t *testing.T) {
+	w := newDelayWorker()
+	sink := w.newSinkLog()
+	w.mgr.MsgQ = manage.NewMsgQueueWithSize(1, 1)
+	w.mgr.MsgQ.Push(&manage.Msg{}, false)
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	name := w.mgr.DelayName()
+	p.Cmd("del", name)
+
+	now := time.Now().Unix()
+	bts, _ := w.mgr.Pack(&manage.Msg{Action: manage.ActReq, Topic: "test", DeadLine: (now + 60) * 1000, DeliverAt: now, V: protocol.VersionHeaders})
+	p.Cmd("zadd", name, now, bts)
+
+	// 队列已满，放回延迟集合
+	w.popDue()
+	logHas(t, sink, "push msgQ timeout, back to delay")
+	v, _ := p.Cmd("zcard", name).Int()
+	assert.Equal(t, 1, v)
+
+	p.Cmd("del", name)
+}
@@ -0,0 +1,302 @@
+package work
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chashu-code/micro-broker/defaults"
+	"github.com/chashu-code/micro-broker/manage"
+	"github.com/chashu-code/micro-broker/pool"
+	rxpool "github.com/mediocregopher/radix.v2/pool"
+	"github.com/mediocregopher/radix.v2/redis"
+	"github.com/uber-go/zap"
+)
+
+// DispatchWorker Job 分发工作器：从 beanstalk 管道 reserve Job，转为 req 投递至消费者，
+// 根据应答 delete 或 release，消费者仅需链接 redis
+type DispatchWorker struct {
+	Worker
+	// IP beanstalk ip
+	IP string
+	// Tube 分发的管道
+	Tube string
+
+	// pid 接收应答的 inbox
+	pid    string
+	seq    uint64
+	client *pool.BeanClient
+}
+
+// DispatchWorkerRun 按 Conf.DispatchTubeMap 为每个管道运行相应并发数的 DispatchWorker
+func DispatchWorkerRun(mgr *manage.Manager, ip string, count int) {
+	for tube, n := range mgr.Conf.DispatchTubeMap {
+		for i := 0; i < n; i++ {
+			w := &DispatchWorker{
+				IP:   ip,
+				Tube: tube,
+				pid:  fmt.Sprintf("dispatch-%s-%d", tube, i),
+			}
+			go w.Run(mgr, "dispatch:"+tube, w.process)
+		}
+	}
+}
+
+// ParseDispatchTubes 解析 "tube1:2,tube2" 为管道及其并发数（ 未指定则为 1 ）
+func ParseDispatchTubes(s string) (map[string]int, error) {
+	tubeMap := make(map[string]int)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		arr := strings.SplitN(item, ":", 2)
+		n := 1
+		if len(arr) == 2 {
+			var err error
+			if n, err = strconv.Atoi(arr[1]); err != nil || n < 1 {
+				return nil, fmt.Errorf("Error dispatch tube: %s", item)
+			}
+		}
+		tubeMap[arr[0]] = n
+	}
+	return tubeMap, nil
+}
+
+func (w *DispatchWorker) process() {
+	durPause := time.Duration(w.mgr.Conf.WrkPauseSecs) * time.Second
+
+	c := w.beanClient()
+	if c == nil {
+		time.Sleep(durPause)
+		return
+	}
+
+	// 超时返回，以便及时响应关闭
+	id, body, err := c.Reserve(w.Tube, durPause)
+	if err != nil {
+		if !pool.IsTimeout(err) {
+			w.Log.Error("reserve job fail", zap.Error(err))
+			time.Sleep(durPause)
+		}
+		return
+	}
+
+	w.dispatch(c, id, body)
+}
+
+// beanClient 独占的 BeanClient（ reserve 的 Job 只能由同一链接 delete/release/touch ）
+func (w *DispatchWorker) beanClient() *pool.BeanClient {
+	if w.client != nil && w.client.IsAble() {
+		return w.client
+	}
+
+	p, _, err := w.beanPoolMap.FetchOrNew(w.IP, w.mgr.Conf.JobPoolSize)
+	if err != nil {
+		w.Log.Error("fetch job pool fail", zap.Error(err))
+		return nil
+	}
+
+	if w.client != nil {
+		w.client.Close()
+	}
+	w.client = pool.NewBeanClient(p.Addr)
+	if !w.client.IsAble() {
+		w.Log.Error("connect beanstalk fail", zap.Error(w.client.LastCritical))
+		return nil
+	}
+	return w.client
+}
+
+// dispatch 将 Job 转为 req 放入 MsgQ，等待应答后处理 Job
+func (w *DispatchWorker) dispatch(c *pool.BeanClient, id uint64, body []byte) {
+	job, err := w.mgr.Unpack(body)
+	if err != nil {
+		// 无法解析，重试无意义，直接搁置
+		w.Log.Error("unpack job fail, bury", zap.Uint64("id", id), zap.Error(err))
+		w.mgr.Counter.Incr("dispatch.bury")
+		if err = c.Bury(id, 0); err != nil {
+			w.Log.Error("bury job fail", zap.Uint64("id", id), zap.Error(err))
+		}
+		return
+	}
+
+	req := w.jobToReq(id, job)
+
+	// 大数据凭证存于本机 redis，还原后由 CarryWorker 按目标重新存取（ job 保留凭证，删除时一并删除 ）
+	if _, err = w.mgr.Claims.Load(defaults.IPLocal, req); err != nil {
+		w.Log.Warn("load job claim fail", zap.Error(err))
+	}
+
+	w.logMsg("dispatch job --->>", req)
+
+	var res *manage.Msg
+	if w.mgr.MsgQ.Push(req, true) {
+		res = w.waitRes(c, id, req)
+	} else {
+		w.Log.Error("push msgQ timeout", msgPackField(req))
+	}
+
+	w.finish(c, id, job, res)
+}
+
+// jobToReq 构造 req，应答将投递至本工作器的 inbox
+func (w *DispatchWorker) jobToReq(id uint64, job *manage.Msg) *manage.Msg {
+	// 每次分发使用不同的 RID，避免 release 后重新分发被去重，及误收上次分发的应答
+	w.seq++
+
+	req := job.Clone(manage.ActReq)
+	req.BID = w.mgr.IP()
+	req.RID = w.pid + "|" + strconv.FormatUint(id, 10) + "/" + strconv.FormatUint(w.seq, 10)
+	req.SetTimeout(time.Duration(w.mgr.Conf.DispatchTimeoutSecs) * time.Second)
+	req.DeliverAt = 0
+	return req
+}
+
+// waitRes 等待 req 的应答，期间按 touchSecs touch Job；超时或关闭时返回 nil
+func (w *DispatchWorker) waitRes(c *pool.BeanClient, id uint64, req *manage.Msg) *manage.Msg {
+	p, _, err := w.redisPoolMap.FetchOrNew(defaults.IPLocal, w.mgr.Conf.PoolSize)
+	if err != nil {
+		w.Log.Error("get local redis pool fail", zap.Error(err))
+		return nil
+	}
+
+	inbox := w.mgr.Inbox(w.pid)
+	touchSecs := w.touchSecs(c, id)
+	durTouch := time.Duration(touchSecs) * time.Second
+	lastTouch := time.Now()
+
+	// 每次等待不超过 touch 间隔，以便及时 touch；blpop 超时为 0 将一直阻塞
+	popSecs := w.mgr.Conf.WrkPauseSecs
+	if touchSecs > 0 && touchSecs < popSecs {
+		popSecs = touchSecs
+	}
+	if popSecs < 1 {
+		popSecs = 1
+	}
+
+	// 多等 1 秒，以便收到 ClearWorker 的超时应答
+	for manage.NowMs() <= req.DeadLine+1000 && !w.mgr.IsShutdown() {
+		if res := w.popRes(p, inbox, req, popSecs); res != nil {
+			return res
+		}
+
+		if durTouch > 0 && time.Since(lastTouch) >= durTouch {
+			lastTouch = time.Now()
+			if err = c.Touch(id); err != nil {
+				w.Log.Warn("touch job fail", zap.Uint64("id", id), zap.Error(err))
+			}
+		}
+	}
+
+	w.mgr.Counter.Incr("dispatch.timeout")
+	w.Log.Warn("wait job res timeout", msgPackField(req))
+	return nil
+}
+
+// touchSecs touch Job 的间隔秒数：DispatchTouchSecs，Job 的 TTR 较短时为 TTR 的一半（ 至少 1 秒 ），
+// 避免 beanstalkd 在应答前自动 release 而重复分发；DispatchTouchSecs 为 0 则不 touch
+func (w *DispatchWorker) touchSecs(c *pool.BeanClient, id uint64) int {
+	secs := w.mgr.Conf.DispatchTouchSecs
+	if secs <= 0 {
+		return 0
+	}
+
+	info, err := c.StatsJob(id)
+	if err != nil {
+		w.Log.Warn("stats job fail", zap.Uint64("id", id), zap.Error(err))
+		return secs
+	}
+
+	if ttr, _ := strconv.Atoi(info["ttr"]); ttr > 0 && ttr/2 < secs {
+		secs = ttr / 2
+		if secs < 1 {
+			secs = 1
+		}
+	}
+	return secs
+}
+
+// popRes 从 inbox 获取 req 的应答（ 最多等待 secs 秒 ），丢弃之前分发的迟到应答
+func (w *DispatchWorker) popRes(p *rxpool.Pool, inbox string, req *manage.Msg, secs int) *manage.Msg {
+	resp := p.Cmd("blpop", inbox, secs)
+	if resp.IsType(redis.Nil) {
+		return nil
+	}
+
+	lstBytes, err := resp.ListBytes()
+	if err != nil || len(lstBytes) != 2 {
+		w.Log.Error("pop job res fail", zap.Error(err))
+		time.Sleep(time.Duration(secs) * time.Second)
+		return nil
+	}
+
+	res, err := w.mgr.Unpack(lstBytes[1])
+	if err != nil {
+		w.Log.Error("unpack job res fail", zap.Error(err))
+		return nil
+	}
+
+	if res.RID != req.RID {
+		w.mgr.Counter.Incr("dispatch.stale")
+		w.Log.Warn("stale job res, drop", msgPackField(res))
+		return nil
+	}
+
+	return res
+}
+
+// finish 应答成功则 delete Job，否则 release（ 超过 DispatchMaxReleases 则搁置 ）
+func (w *DispatchWorker) finish(c *pool.BeanClient, id uint64, job *manage.Msg, res *manage.Msg) {
+	var err error
+	var state string
+
+	if res != nil {
+		w.logMsg("dispatch res <<---", res)
+	}
+
+	pri, _, _, _ := job.PutArgs()
+
+	switch {
+	case res != nil && res.Code == "0":
+		state = "ok"
+		if err = c.Delete(id); err == nil {
+			if errDrop := w.mgr.Claims.Drop(defaults.IPLocal, manage.ClaimRef(job.Data)); errDrop != nil {
+				w.Log.Warn("drop job claim fail", zap.Uint64("id", id), zap.Error(errDrop))
+			}
+		}
+	case w.isReleasedOut(c, id):
+		state = "bury"
+		err = c.Bury(id, pri)
+	default:
+		state = "release"
+		err = c.Release(id, pri, time.Duration(w.mgr.Conf.DispatchReleaseSecs)*time.Second)
+	}
+
+	w.mgr.Counter.Incr("dispatch." + state)
+	if err != nil {
+		w.Log.Error(state+" job fail", zap.Uint64("id", id), zap.Error(err))
+	}
+}
+
+// isReleasedOut 是否已达 release 次数上限
+func (w *DispatchWorker) isReleasedOut(c *pool.BeanClient, id uint64) bool {
+	if w.mgr.Conf.DispatchMaxReleases <= 0 {
+		return false
+	}
+
+	info, err := c.StatsJob(id)
+	if err != nil {
+		w.Log.Warn("stats job fail", zap.Uint64("id", id), zap.Error(err))
+		return false
+	}
+
+	releases, _ := strconv.Atoi(info["releases"])
+	return releases >= w.mgr.Conf.DispatchMaxReleases
+}
+
+func (w *DispatchWorker) logMsg(info string, msg *manage.Msg) {
+	w.Log.Info(info, msgPackField(msg))
+}
@@ -0,0 +1,181 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chashu-code/micro-broker/defaults"
+	"github.com/chashu-code/micro-broker/manage"
+	"github.com/chashu-code/micro-broker/pool"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDispatchWorker(tube string) *DispatchWorker {
+	w := &DispatchWorker{
+		IP:   defaults.IPLocal,
+		Tube: tube,
+		pid:  "dispatch-" + tube + "-0",
+	}
+	w.mgr = newManager()
+	w.redisPoolMap = pool.NewRedisPoolMap()
+	w.beanPoolMap = pool.NewBeanPoolMap()
+	w.mgr.RedisPoolMap = w.redisPoolMap
+	w.mgr.Conf.WrkPauseSecs = 1
+	return w
+}
+
+func Test_DispatchWorker_ParseDispatchTubes(t *testing.T) {
+	tubeMap, err := ParseDispatchTubes("")
+	assert.Nil(t, err)
+	assert.Empty(t, tubeMap)
+
+	tubeMap, err = ParseDispatchTubes("a:2, b,")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"a": 2, "b": 1}, tubeMap)
+
+	_, err = ParseDispatchTubes("a:x")
+	assert.Contains(t, err.Error(), "Error dispatch tube")
+	_, err = ParseDispatchTubes("a:0")
+	assert.NotNil(t, err)
+}
+
+func Test_DispatchWorker_process(t *testing.T) {
+	tube := "test-dispatch"
+	w := newDispatchWorker(tube)
+	sink := w.newSinkLog()
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	inbox := w.mgr.Inbox(w.pid)
+	p.Cmd("del", inbox)
+
+	c := pool.NewBeanClient("127.0.0.1:11300")
+	defer c.Close()
+
+	putJob := func() uint64 {
+		job := &manage.Msg{Action: manage.ActJob, Topic: tube, RID: "0|job", TID: "tid-job", Code: "1|0|60", Data: "hello", V: 1}
+		bts, _ := w.mgr.Pack(job)
+		id, err := c.Put(tube, bts, 1, 0, time.Minute)
+		assert.Nil(t, err)
+		return id
+	}
+	// 模拟消费者：取出 req，以 code 应答
+	consume := func(code string) {
+		req, ok := w.mgr.MsgQ.Pop(true)
+		assert.True(t, ok)
+		assert.Equal(t, manage.ActReq, req.Action)
+		assert.Equal(t, tube, req.Topic)
+		assert.Equal(t, "hello", req.Data)
+		assert.Equal(t, "tid-job", req.TID)
+
+		res := req.Clone(manage.ActRes)
+		res.Code = code
+		bts, _ := w.mgr.Pack(res)
+		p.Cmd("rpush", inbox, bts)
+	}
+
+	// 无 Job，reserve 超时
+	w.process()
+	logNotHas(t, sink, "reserve job fail")
+
+	// 应答成功，delete
+	id := putJob()
+	go consume("0")
+	w.process()
+	logHas(t, sink, "dispatch job --->>", "dispatch res <<---")
+	assert.Equal(t, 1, w.mgr.Counter.Get("dispatch.ok"))
+	_, err := c.Peek(id)
+	assert.True(t, pool.IsNotFound(err))
+
+	// 应答失败，release
+	w.mgr.Conf.DispatchReleaseSecs = 0
+	id = putJob()
+	go consume("500")
+	w.process()
+	assert.Equal(t, 1, w.mgr.Counter.Get("dispatch.release"))
+	info, _ := c.StatsJob(id)
+	assert.Equal(t, "ready", info["state"])
+	assert.Equal(t, "1", info["releases"])
+
+	// 已达 release 上限，搁置
+	w.mgr.Conf.DispatchMaxReleases = 1
+	go consume("500")
+	w.process()
+	assert.Equal(t, 1, w.mgr.Counter.Get("dispatch.bury"))
+	info, _ = c.StatsJob(id)
+	assert.Equal(t, "buried", info["state"])
+	c.Delete(id)
+}
+
+func Test_DispatchWorker_waitResTimeout(t *testing.T) {
+	tube := "test-dispatch-timeout"
+	w := newDispatchWorker(tube)
+	sink := w.newSinkLog()
+	w.mgr.Conf.DispatchTimeoutSecs = 0
+	w.mgr.Conf.DispatchTouchSecs = 1
+	p, _, _ := w.redisPoolMap.FetchOrNew(defaults.IPLocal, 1)
+	inbox := w.mgr.Inbox(w.pid)
+	p.Cmd("del", inbox)
+
+	c := pool.NewBeanClient("127.0.0.1:11300")
+	defer c.Close()
+	id, _ := c.Put(tube, []byte("x"), 0, 0, time.Minute)
+	c.Reserve(tube, time.Second)
+
+	req := w.jobToReq(id, &manage.Msg{Topic: tube, V: 1})
+	assert.Contains(t, req.RID, w.pid+"|")
+
+	// 之前分发的迟到应答，丢弃
+	stale := req.Clone(manage.ActRes)
+	stale.RID = w.pid + "|0/0"
+	bts, _ := w.mgr.Pack(stale)
+	p.Cmd("rpush", inbox, bts)
+
+	assert.Nil(t, w.waitRes(c, id, req))
+	logHas(t, sink, "stale job res, drop", "wait job res timeout")
+	logNotHas(t, sink, "touch job fail")
+	assert.Equal(t, 1, w.mgr.Counter.Get("dispatch.stale"))
+	assert.Equal(t, 1, w.mgr.Counter.Get("dispatch.timeout"))
+	c.Delete(id)
+}
+
+func Test_DispatchWorker_touchSecs(t *testing.T) {
+	tube := "test-dispatch-touch"
+	w := newDispatchWorker(tube)
+
+	c := pool.NewBeanClient("127.0.0.1:11300")
+	defer c.Close()
+	id, _ := c.Put(tube, []byte("x"), 0, 0, 10*time.Second)
+
+	// TTR 较短，取其一半
+	assert.Equal(t, 5, w.touchSecs(c, id))
+
+	w.mgr.Conf.DispatchTouchSecs = 3
+	assert.Equal(t, 3, w.touchSecs(c, id))
+
+	w.mgr.Conf.DispatchTouchSecs = 0
+	assert.Equal(t, 0, w.touchSecs(c, id))
+	c.Delete(id)
+
+	w.mgr.Conf.DispatchTouchSecs = 60
+	id, _ = c.Put(tube, []byte("x"), 0, 0, time.Second)
+	assert.Equal(t, 1, w.touchSecs(c, id))
+	c.Delete(id)
+}
+
+func Test_DispatchWorker_dispatchUnpackFail(t *testing.T) {
+	tube := "test-dispatch-bad"
+	w := newDispatchWorker(tube)
+	sink := w.newSinkLog()
+
+	c := pool.NewBeanClient("127.0.0.1:11300")
+	defer c.Close()
+	c.Put(tube, []byte{0, 1}, 0, 0, time.Minute)
+	id, body, err := c.Reserve(tube, time.Second)
+	assert.Nil(t, err)
+
+	// 无法解析，搁置
+	w.dispatch(c, id, body)
+	logHas(t, sink, "unpack job fail, bury")
+	info, _ := c.StatsJob(id)
+	assert.Equal(t, "buried", info["state"])
+	c.Delete(id)
+}
@@ -6,10 +6,26 @@ import (
 
 	"github.com/chashu-code/micro-broker/defaults"
 	"github.com/chashu-code/micro-broker/manage"
+	rxpool "github.com/mediocregopher/radix.v2/pool"
 	"github.com/mediocregopher/radix.v2/redis"
+	"github.com/mediocregopher/radix.v2/util"
 	"github.com/uber-go/zap"
 )
 
+var errMsgDead = errors.New("msg is dead")
+
+// luaPopBatch 原子地从 outbox 头部取出至多 ARGV[1] 个消息，若指定 KEYS[2] 则同时移入处理中列表
+const luaPopBatch = `
+local msgs = redis.call('lrange', KEYS[1], 0, ARGV[1] - 1)
+if #msgs > 0 then
+	redis.call('ltrim', KEYS[1], #msgs, -1)
+	if KEYS[2] then
+		redis.call('rpush', KEYS[2], unpack(msgs))
+	end
+end
+return msgs
+`
+
 // SubWorker 订阅处理工作器
 type SubWorker struct {
 	Worker
@@ -21,6 +37,10 @@ type SubWorker struct {
 
 // SubWorkerRun 运行多个SubWorker
 func SubWorkerRun(mgr *manage.Manager, destIP string, count int) {
+	if mgr.Conf.Reliable {
+		recoverProcessing(mgr, destIP)
+	}
+
 	for i := 0; i < count; i++ {
 		w := &SubWorker{
 			destIP: destIP,
@@ -30,6 +50,37 @@ func SubWorkerRun(mgr *manage.Manager, destIP string, count int) {
 	}
 }
 
+// recoverProcessing 启动时，将上次未处理完成的消息放回 outbox 头部（保持原有顺序）
+func recoverProcessing(mgr *manage.Manager, destIP string) {
+	log := mgr.Log.With(zap.String("wrk", "sub:"+destIP))
+	pool := mgr.RedisPoolMap.Fetch(destIP)
+	if pool == nil {
+		log.Warn("recover processing fail, can't fetch redis pool")
+		return
+	}
+
+	subIP := adjustSubIP(mgr.IP(), destIP)
+	processing := mgr.Processing(subIP)
+	outbox := mgr.Outbox(subIP)
+
+	count := 0
+	for {
+		res := pool.Cmd("rpoplpush", processing, outbox)
+		if res.Err != nil {
+			log.Error("recover processing fail", zap.Error(res.Err))
+			break
+		}
+		if res.IsType(redis.Nil) {
+			break
+		}
+		count++
+	}
+
+	if count > 0 {
+		log.Info("recover processing msgs", zap.Int("count", count))
+	}
+}
+
 func adjustSubIP(mgrIP, destIP string) string {
 	if mgrIP == destIP {
 		return defaults.IPLocal
@@ -38,6 +89,14 @@ func adjustSubIP(mgrIP, destIP string) string {
 }
 
 func (w *SubWorker) resToMsg(res *redis.Resp) (*manage.Msg, error) {
+	bts, err := w.resToBytes(res)
+	if err != nil || bts == nil {
+		return nil, err
+	}
+	return w.bytesToMsg(bts)
+}
+
+func (w *SubWorker) resToBytes(res *redis.Resp) ([]byte, error) {
 	if res.IsType(redis.Nil) {
 		return nil, nil
 	}
@@ -48,15 +107,22 @@ func (w *SubWorker) resToMsg(res *redis.Resp) (*manage.Msg, error) {
 		return nil, err
 	}
 
-	bts := lstBytes[1]
+	return lstBytes[1], nil
+}
+
+func (w *SubWorker) bytesToMsg(bts []byte) (*manage.Msg, error) {
 	msg, err := w.mgr.Unpack(bts)
 
 	if err != nil {
+		dl := manage.NewDeadLetter(manage.DLStageUnpack, err, nil, bts)
+		dl.DestIP, dl.Key = w.destIP, w.mgr.Outbox(w.subIP)
+		w.deadLetter(dl)
 		return nil, err
 	}
 
-	if msg.IsDead() {
-		return msg, errors.New("msg is dead")
+	// 应答即使已过 DeadLine 也交由 CarryWorker 处理，以区分迟到或重复的应答
+	if msg.Action != manage.ActRes && msg.IsDead() {
+		return msg, errMsgDead
 	}
 
 	return msg, nil
@@ -72,21 +138,148 @@ func (w *SubWorker) process() {
 		return
 	}
 
-	res := pool.Cmd("blpop", w.mgr.Outbox(w.subIP), w.mgr.Conf.PopTimeoutSecs)
+	// 消息队列已满，暂停获取，消息留在 redis 中
+	if w.mgr.MsgQ.IsFull() {
+		time.Sleep(time.Duration(w.mgr.Conf.MsgQueueFullPauseMSecs) * time.Millisecond)
+		return
+	}
 
-	msg, err := w.resToMsg(res)
+	outbox := w.mgr.Outbox(w.subIP)
+
+	// 按队列空位批量获取，outbox 为空时再阻塞等待
+	if size := w.batchSize(); size > 1 {
+		lstBytes, ack, err := w.popBatch(pool, outbox, size)
+		if err != nil {
+			w.Log.Error("pop batch fail", zap.Error(err))
+		}
+		if len(lstBytes) > 0 {
+			for i, bts := range lstBytes {
+				// 队列仍满，其余消息一并放回，不再逐个等待
+				if !w.handle(bts, ack) {
+					w.backToOutbox(pool, outbox, lstBytes[i:], ack)
+					return
+				}
+			}
+			return
+		}
+	}
+
+	var ack *manage.MsgAck
+	var bts []byte
+	var err error
+
+	if w.mgr.Conf.Reliable {
+		// 移入处理中列表，处理完成后再移除
+		processing := w.mgr.Processing(w.subIP)
+		res := pool.Cmd("blmove", outbox, processing, "LEFT", "RIGHT", w.mgr.Conf.PopTimeoutSecs)
+		if bts, err = w.movedResToBytes(res); bts != nil {
+			ack = &manage.MsgAck{IP: w.destIP, Key: processing, Raw: bts}
+		}
+	} else {
+		res := pool.Cmd("blpop", outbox, w.mgr.Conf.PopTimeoutSecs)
+		bts, err = w.resToBytes(res)
+	}
 
 	if err != nil {
-		w.Log.Error("unexpected msg", msgPackField(msg), zap.Error(err))
+		w.Log.Error("unexpected msg", zap.Error(err))
 		return
 	}
 
-	if msg == nil { // redis list empty
+	if bts == nil { // redis list empty
 		return
 	}
 
-	ok := w.mgr.MsgQ.Push(msg, true)
-	if !ok {
-		w.Log.Error("push msgQ timeout", msgPackField(msg))
+	if !w.handle(bts, ack) {
+		w.backToOutbox(pool, outbox, [][]byte{bts}, ack)
+	}
+}
+
+// batchSize 单次批量获取的数量，不超过消息队列空位
+func (w *SubWorker) batchSize() int {
+	size := w.mgr.Conf.SubBatchSize
+	if vacant := w.mgr.MsgQ.Vacant(); vacant < size {
+		size = vacant
+	}
+	return size
+}
+
+// popBatch 非阻塞地从 outbox 获取至多 size 个消息，可靠模式下返回的 ack 仅含 IP、Key
+func (w *SubWorker) popBatch(pool *rxpool.Pool, outbox string, size int) ([][]byte, *manage.MsgAck, error) {
+	var res *redis.Resp
+	var ack *manage.MsgAck
+
+	if w.mgr.Conf.Reliable {
+		processing := w.mgr.Processing(w.subIP)
+		ack = &manage.MsgAck{IP: w.destIP, Key: processing}
+		res = util.LuaEval(pool, luaPopBatch, 2, outbox, processing, size)
+	} else {
+		res = util.LuaEval(pool, luaPopBatch, 1, outbox, size)
+	}
+
+	lstBytes, err := res.ListBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return lstBytes, ack, nil
+}
+
+// handle 处理从 outbox 获取的消息 bytes，ack 为 nil 则无需确认
+// 放入消息队列超时则返回 false，由调用方放回 outbox
+func (w *SubWorker) handle(bts []byte, ack *manage.MsgAck) bool {
+	if ack != nil {
+		ack = &manage.MsgAck{IP: ack.IP, Key: ack.Key, Raw: bts}
 	}
+
+	msg, err := w.bytesToMsg(bts)
+	if err != nil {
+		w.Log.Error("unexpected msg", msgPackField(msg), zap.Error(err))
+		if err == errMsgDead {
+			w.replyTimeout(msg)
+		}
+		w.ack(ack)
+		return true
+	}
+
+	msg.Ack = ack
+	if !w.mgr.MsgQ.Push(msg, true) {
+		w.Log.Warn("push msgQ timeout, back to outbox", msgPackField(msg))
+		return false
+	}
+	return true
+}
+
+// backToOutbox 将未处理的消息按原顺序放回 outbox 头部，待队列有空位后再处理，失败则存入死信队列
+func (w *SubWorker) backToOutbox(pool *rxpool.Pool, outbox string, lstBytes [][]byte, ack *manage.MsgAck) {
+	// lpush 逐个插入头部，故逆序插入
+	args := []interface{}{outbox}
+	for i := len(lstBytes) - 1; i >= 0; i-- {
+		args = append(args, lstBytes[i])
+	}
+
+	res := pool.Cmd("lpush", args...)
+	if res.Err != nil {
+		w.Log.Error("back to outbox fail", zap.Error(res.Err), zap.Int("count", len(lstBytes)))
+	}
+
+	for _, bts := range lstBytes {
+		if res.Err != nil {
+			msg, _ := w.mgr.Unpack(bts)
+			dl := manage.NewDeadLetter(manage.DLStagePush, res.Err, msg, bts)
+			dl.DestIP, dl.Key = w.destIP, outbox
+			w.deadLetter(dl)
+		}
+
+		if ack != nil {
+			w.ack(&manage.MsgAck{IP: ack.IP, Key: ack.Key, Raw: bts})
+		}
+	}
+}
+
+func (w *SubWorker) movedResToBytes(res *redis.Resp) ([]byte, error) {
+	if res.IsType(redis.Nil) {
+		return nil, nil
+	}
+
+	return res.Bytes()
 }
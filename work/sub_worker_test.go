@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"strings"
 	"testing"
-	"time"
 
 	"github.com/chashu-code/micro-broker/defaults"
 	"github.com/chashu-code/micro-broker/manage"
@@ -84,7 +83,7 @@ func Test_SubWorker_resToMsg(t *testing.T) {
 	assert.Contains(t, err.Error(), "msg is dead")
 
 	// ok
-	bts = newMsgBytes(1, time.Now().Unix(), w.mgr)
+	bts = newMsgBytes(1, manage.NowMs(), w.mgr)
 	r = redis.NewRespFlattenedStrings([][]byte{[]byte{0}, bts})
 	msg, err = w.resToMsg(r)
 	assert.Nil(t, err)
@@ -96,6 +95,7 @@ func Test_SubWorker_process(t *testing.T) {
 	w := newSubWorker()
 	sink := w.newSinkLog()
 	w.redisPoolMap = pool.NewRedisPoolMap()
+	w.mgr.RedisPoolMap = w.redisPoolMap
 	w.mgr.Conf.WrkPauseSecs = 0
 	w.mgr.Conf.PopTimeoutSecs = 1
 	w.process()
@@ -107,12 +107,20 @@ func Test_SubWorker_process(t *testing.T) {
 	sink = w.newSinkLog()
 	w.process()
 
-	bts := newMsgBytes(0, time.Now().Unix(), w.mgr)
+	bts := newMsgBytes(0, manage.NowMs(), w.mgr)
 	p.Cmd("rpush", lstName, bts)
+	p.Cmd("del", w.mgr.DLQName())
 	sink = w.newSinkLog()
 	w.process()
 	logHas(t, sink, "error version")
 	logNotHas(t, sink, "msgPack")
+	// 无法解析，存入死信队列
+	dls, _ := w.mgr.DLQ.List(0, -1)
+	assert.Equal(t, 1, len(dls))
+	assert.Equal(t, manage.DLStageUnpack, dls[0].Stage)
+	assert.Equal(t, lstName, dls[0].Key)
+	assert.Equal(t, bts, dls[0].Raw)
+	p.Cmd("del", w.mgr.DLQName())
 
 	bts = newMsgBytes(1, 0, w.mgr)
 	p.Cmd("rpush", lstName, bts)
@@ -121,7 +129,7 @@ func Test_SubWorker_process(t *testing.T) {
 	logHas(t, sink, "msg is dead")
 	logHas(t, sink, "msgPack")
 
-	bts = newMsgBytes(1, time.Now().Unix(), w.mgr)
+	bts = newMsgBytes(1, manage.NowMs(), w.mgr)
 	p.Cmd("rpush", lstName, bts)
 	w.newSinkLog()
 	_, ok := w.mgr.MsgQ.Pop(false)
@@ -130,3 +138,208 @@ func Test_SubWorker_process(t *testing.T) {
 	_, ok = w.mgr.MsgQ.Pop(false)
 	assert.True(t, ok)
 }
+
+func Test_SubWorker_replyTimeout(t *testing.T) {
+	w := newSubWorker()
+	w.newSinkLog()
+
+	// 非请求，不应答
+	w.replyTimeout(&manage.Msg{Action: manage.ActRes, Topic: "test", V: 1})
+	_, ok := w.mgr.MsgQ.Pop(false)
+	assert.False(t, ok)
+
+	msg := &manage.Msg{
+		Action: manage.ActReq,
+		RID:    "0|timeout",
+		Topic:  "test",
+		V:      1,
+	}
+	w.replyTimeout(msg)
+	msgRes, ok := w.mgr.MsgQ.Pop(false)
+	assert.True(t, ok)
+	assert.Equal(t, manage.ActRes, msgRes.Action)
+	assert.Equal(t, manage.CodeTimeout, msgRes.Code)
+	assert.Equal(t, msg.RID, msgRes.RID)
+	assert.Equal(t, 1, w.mgr.Counter.Get("timeout.test"))
+}
+
+func Test_SubWorker_processQueueFull(t *testing.T) {
+	w := newSubWorker()
+	w.redisPoolMap = pool.NewRedisPoolMap()
+	w.mgr.RedisPoolMap = w.redisPoolMap
+	w.mgr.Conf.PopTimeoutSecs = 1
+	w.mgr.MsgQ = manage.NewMsgQueueWithSize(1, 1)
+
+	p, _, _ := w.redisPoolMap.FetchOrNew(w.mgr.IP(), 1)
+	lstName := w.mgr.Outbox(w.subIP)
+	p.Cmd("del", lstName)
+
+	bts := newMsgBytes(1, manage.NowMs()+60000, w.mgr)
+	p.Cmd("rpush", lstName, bts)
+
+	// 队列已满，暂停获取
+	w.mgr.MsgQ.Push(&manage.Msg{}, false)
+	sink := w.newSinkLog()
+	w.process()
+	assert.Empty(t, sink.Logs())
+	v, _ := p.Cmd("llen", lstName).Int()
+	assert.Equal(t, 1, v)
+
+	// 队列有空位，继续获取
+	w.mgr.MsgQ.Pop(false)
+	w.process()
+	v, _ = p.Cmd("llen", lstName).Int()
+	assert.Equal(t, 0, v)
+	assert.True(t, w.mgr.MsgQ.IsFull())
+}
+
+func Test_SubWorker_processReliable(t *testing.T) {
+	w := newSubWorker()
+	w.newSinkLog()
+	w.redisPoolMap = pool.NewRedisPoolMap()
+	w.mgr.RedisPoolMap = w.redisPoolMap
+	w.mgr.Conf.Reliable = true
+	w.mgr.Conf.PopTimeoutSecs = 1
+
+	p, _, _ := w.redisPoolMap.FetchOrNew(w.mgr.IP(), 1)
+	outbox := w.mgr.Outbox(w.subIP)
+	processing := w.mgr.Processing(w.subIP)
+	p.Cmd("del", outbox, processing)
+
+	// 过期消息，处理后直接确认
+	p.Cmd("rpush", outbox, newMsgBytes(1, 0, w.mgr))
+	w.process()
+	v, _ := p.Cmd("llen", processing).Int()
+	assert.Equal(t, 0, v)
+
+	// 正常消息，移入处理中列表，直至确认
+	bts := newMsgBytes(1, manage.NowMs()+60000, w.mgr)
+	p.Cmd("rpush", outbox, bts)
+	w.process()
+	v, _ = p.Cmd("llen", processing).Int()
+	assert.Equal(t, 1, v)
+
+	msg, ok := w.mgr.MsgQ.Pop(false)
+	assert.True(t, ok)
+	assert.Equal(t, processing, msg.Ack.Key)
+	assert.Equal(t, bts, msg.Ack.Raw)
+
+	w.ack(msg.Ack)
+	v, _ = p.Cmd("llen", processing).Int()
+	assert.Equal(t, 0, v)
+}
+
+func Test_SubWorker_recoverProcessing(t *testing.T) {
+	mgr := newManager()
+	mgr.RedisPoolMap = pool.NewRedisPoolMap()
+	destIP := mgr.IP()
+	subIP := adjustSubIP(mgr.IP(), destIP)
+
+	// 无法获取 pool
+	recoverProcessing(mgr, destIP)
+
+	p, _, _ := mgr.RedisPoolMap.FetchOrNew(destIP, 1)
+	outbox := mgr.Outbox(subIP)
+	processing := mgr.Processing(subIP)
+	p.Cmd("del", outbox, processing)
+
+	p.Cmd("rpush", processing, "a", "b")
+	p.Cmd("rpush", outbox, "c")
+	recoverProcessing(mgr, destIP)
+
+	lst, _ := p.Cmd("lrange", outbox, 0, -1).List()
+	assert.Equal(t, []string{"a", "b", "c"}, lst)
+	v, _ := p.Cmd("llen", processing).Int()
+	assert.Equal(t, 0, v)
+	p.Cmd("del", outbox)
+}
+
+func Test_SubWorker_processBatch(t *testing.T) {
+	w := newSubWorker()
+	w.newSinkLog()
+	w.redisPoolMap = pool.NewRedisPoolMap()
+	w.mgr.RedisPoolMap = w.redisPoolMap
+	w.mgr.Conf.SubBatchSize = 3
+
+	p, _, _ := w.redisPoolMap.FetchOrNew(w.mgr.IP(), 1)
+	outbox := w.mgr.Outbox(w.subIP)
+	processing := w.mgr.Processing(w.subIP)
+	p.Cmd("del", outbox, processing)
+
+	for i := 0; i < 4; i++ {
+		p.Cmd("rpush", outbox, newMsgBytes(1, manage.NowMs()+60000, w.mgr))
+	}
+
+	// 单次至多获取 SubBatchSize 个
+	w.process()
+	assert.Equal(t, 3, w.mgr.MsgQ.Len())
+	v, _ := p.Cmd("llen", outbox).Int()
+	assert.Equal(t, 1, v)
+
+	// 不超过消息队列空位
+	assert.Equal(t, 3, w.batchSize())
+	w.mgr.Conf.SubBatchSize = w.mgr.MsgQ.Vacant() + 1
+	assert.Equal(t, w.mgr.MsgQ.Vacant(), w.batchSize())
+
+	// 可靠模式，同时移入处理中列表
+	w.mgr.Conf.Reliable = true
+	w.process()
+	v, _ = p.Cmd("llen", processing).Int()
+	assert.Equal(t, 1, v)
+	v, _ = p.Cmd("llen", outbox).Int()
+	assert.Equal(t, 0, v)
+	p.Cmd("del", processing)
+}
+
+func Test_SubWorker_backToOutbox(t *testing.T) {
+	w := newSubWorker()
+	w.newSinkLog()
+	w.redisPoolMap = pool.NewRedisPoolMap()
+	w.mgr.RedisPoolMap = w.redisPoolMap
+
+	p, _, _ := w.redisPoolMap.FetchOrNew(w.mgr.IP(), 1)
+	outbox := w.mgr.Outbox(w.subIP)
+	processing := w.mgr.Processing(w.subIP)
+	p.Cmd("del", outbox, processing)
+	p.Cmd("rpush", outbox, "d")
+	p.Cmd("rpush", processing, "a", "b", "c")
+
+	// 保持原顺序放回头部，并确认
+	ack := &manage.MsgAck{IP: w.destIP, Key: processing}
+	w.backToOutbox(p, outbox, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, ack)
+	lst, _ := p.Cmd("lrange", outbox, 0, -1).List()
+	assert.Equal(t, []string{"a", "b", "c", "d"}, lst)
+	v, _ := p.Cmd("llen", processing).Int()
+	assert.Equal(t, 0, v)
+	p.Cmd("del", outbox)
+}
+
+func benchmarkSubWorkerProcess(b *testing.B, batchSize int) {
+	w := newSubWorker()
+	w.newSinkLog()
+	w.redisPoolMap = pool.NewRedisPoolMap()
+	w.mgr.Conf.SubBatchSize = batchSize
+	w.mgr.MsgQ = manage.NewMsgQueueWithSize(1, b.N+1)
+
+	p, _, _ := w.redisPoolMap.FetchOrNew(w.mgr.IP(), 1)
+	outbox := w.mgr.Outbox(w.subIP)
+	p.Cmd("del", outbox)
+
+	bts := newMsgBytes(1, manage.NowMs()+3600000, w.mgr)
+	for i := 0; i < b.N; i++ {
+		p.Cmd("rpush", outbox, bts)
+	}
+
+	b.ResetTimer()
+	for w.mgr.MsgQ.Len() < b.N {
+		w.process()
+	}
+}
+
+func Benchmark_SubWorker_processSingle(b *testing.B) {
+	benchmarkSubWorkerProcess(b, 1)
+}
+
+func Benchmark_SubWorker_processBatch(b *testing.B) {
+	benchmarkSubWorkerProcess(b, 10)
+}
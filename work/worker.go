@@ -53,6 +53,56 @@ func (w *Worker) Run(mgr *manage.Manager, wrkName string, proc WrkProcFn) {
 	}
 }
 
+// ack 可靠模式下，消息处理完成，从 redis 处理中列表移除
+func (w *Worker) ack(a *manage.MsgAck) {
+	if a == nil {
+		return
+	}
+
+	p := w.redisPoolMap.Fetch(a.IP)
+	if p == nil {
+		w.Log.Error("ack fail, can't fetch redis pool", zap.String("pool", a.IP))
+		return
+	}
+
+	if res := p.Cmd("lrem", a.Key, 1, a.Raw); res.Err != nil {
+		w.Log.Error("ack fail", zap.String("key", a.Key), zap.Error(res.Err))
+	}
+}
+
+// deadLetter 存入死信队列
+func (w *Worker) deadLetter(dl *manage.DeadLetter) {
+	if err := w.mgr.DLQ.Push(dl); err != nil {
+		w.Log.Error("push dead letter fail", zap.String("stage", dl.Stage), zap.Error(err))
+	}
+}
+
+// timeoutRes 构造已过期请求的超时应答，并计数；非请求返回 nil
+func (w *Worker) timeoutRes(msg *manage.Msg) *manage.Msg {
+	if msg.Action != manage.ActReq {
+		return nil
+	}
+
+	w.mgr.Counter.Incr("timeout." + msg.Topic)
+
+	msgRes := msg.Clone(manage.ActRes)
+	msgRes.Code = manage.CodeTimeout
+	msgRes.Data = "request deadline exceeded"
+	return msgRes
+}
+
+// replyTimeout 已过期的请求经 MsgQ 应答超时，不再投递至服务
+func (w *Worker) replyTimeout(msg *manage.Msg) {
+	msgRes := w.timeoutRes(msg)
+	if msgRes == nil {
+		return
+	}
+
+	if !w.mgr.MsgQ.Push(msgRes, true) {
+		w.Log.Error("push msgQ timeout", msgPackField(msgRes))
+	}
+}
+
 // msgPackField 构造一个msgPackField
 func msgPackField(msg *manage.Msg) zap.Field {
 	if msg == nil {